@@ -42,3 +42,19 @@ type Logger interface {
 	// Errorf is for reporting errors.
 	Errorf(format string, args ...interface{})
 }
+
+// StructuredLogger is a Logger that also attaches key-value pair fields to
+// messages, e.g., the instance connection name or a connection ID.
+type StructuredLogger interface {
+	Logger
+	// Debug is for reporting additional information about internal
+	// operations.
+	Debug(msg string, args ...any)
+	// Info is for reporting informational messages.
+	Info(msg string, args ...any)
+	// Error is for reporting errors.
+	Error(msg string, args ...any)
+	// With returns a StructuredLogger that adds the key-value pair fields to
+	// every message.
+	With(args ...any) StructuredLogger
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/healthcheck"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+)
+
+// drainer moves traffic away from the Proxy ahead of shutdown. Draining fails
+// the readiness check, optionally stops accepting new connections on some or
+// all instances, and optionally shuts down once the open connections reach
+// zero or a deadline passes. Existing connections keep running.
+type drainer struct {
+	ctx        context.Context
+	p          *proxy.Client
+	hc         *healthcheck.Check
+	logger     cloudsql.Logger
+	shutdownCh chan<- error
+	// fuse is set in FUSE mode, where sockets are only mounted once a
+	// client connects, so any instance may be named.
+	fuse bool
+
+	// shutdownOnce ensures only the first request that asks for a shutdown
+	// starts waiting for one.
+	shutdownOnce sync.Once
+}
+
+// handle drains the Proxy. It supports the following query params:
+//
+//   - stop-accepting: an instance connection name to stop accepting new
+//     connections for, or "all" for every instance. May be repeated.
+//   - when-idle: if true, shut down once no connections are open.
+//   - deadline: a duration after which to shut down regardless of any open
+//     connections.
+func (d *drainer) handle(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodGet {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := req.URL.Query()
+	var whenIdle bool
+	if v := q.Get("when-idle"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(rw, "invalid when-idle query param", http.StatusBadRequest)
+			return
+		}
+		whenIdle = b
+	}
+	var deadline time.Duration
+	if v := q.Get("deadline"); v != "" {
+		dur, err := time.ParseDuration(v)
+		if err != nil || dur <= 0 {
+			http.Error(rw, "invalid deadline query param", http.StatusBadRequest)
+			return
+		}
+		deadline = dur
+	}
+	all, insts, err := d.stopAccepting(q["stop-accepting"])
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	d.p.Drain(all, insts...)
+	if d.hc != nil {
+		d.hc.NotifyDraining()
+	}
+	d.logger.Infof("Draining: readiness is failing and existing connections are left open")
+	switch {
+	case all:
+		d.logger.Infof("Draining: refusing new connections for every instance")
+	case len(insts) > 0:
+		d.logger.Infof("Draining: refusing new connections for %v", strings.Join(insts, ", "))
+	}
+	if whenIdle || deadline > 0 {
+		d.shutdownOnce.Do(func() {
+			go d.shutdownAfter(whenIdle, deadline)
+		})
+	}
+}
+
+// stopAccepting resolves the stop-accepting query params to the instance
+// connection names that stop accepting, or reports all if every instance
+// does.
+func (d *drainer) stopAccepting(names []string) (bool, []string, error) {
+	mounted := make(map[string]bool)
+	for _, i := range d.p.Instances() {
+		mounted[i.Name] = true
+	}
+	var (
+		all   bool
+		insts []string
+	)
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if n == "all" {
+			all = true
+			continue
+		}
+		if !d.fuse && !mounted[n] {
+			return false, nil, fmt.Errorf("instance %v is not mounted", n)
+		}
+		if !seen[n] {
+			seen[n] = true
+			insts = append(insts, n)
+		}
+	}
+	return all, insts, nil
+}
+
+// shutdownAfter shuts down the Proxy once no connections are open, if
+// whenIdle is set, or once the deadline passes, if it is set.
+func (d *drainer) shutdownAfter(whenIdle bool, deadline time.Duration) {
+	var timeout <-chan time.Time
+	if deadline > 0 {
+		t := time.NewTimer(deadline)
+		defer t.Stop()
+		timeout = t.C
+	}
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-timeout:
+			open, _ := d.p.ConnCount()
+			d.logger.Infof("Drain deadline (%v) reached with %d open connection(s)", deadline, open)
+		case <-tick.C:
+			if open, _ := d.p.ConnCount(); !whenIdle || open > 0 {
+				continue
+			}
+			d.logger.Infof("Drain complete, no connections are open")
+		}
+		select {
+		case d.shutdownCh <- errDrained:
+		case <-d.ctx.Done():
+			// The proxy is already exiting.
+		}
+		return
+	}
+}
@@ -38,6 +38,16 @@ var (
 		Err:  errors.New("/quitquitquit received request"),
 		Code: 0, // This error guarantees a clean exit.
 	}
+
+	errDrained = &exitError{
+		Err:  errors.New("/drain completed"),
+		Code: 0, // This error guarantees a clean exit.
+	}
+
+	errUpgraded = &exitError{
+		Err:  errors.New("upgraded to a new process"),
+		Code: 0, // This error guarantees a clean exit.
+	}
 )
 
 func newBadCommandError(msg string) error {
@@ -19,10 +19,12 @@ import "github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
 // Option is a function that configures a Command.
 type Option func(*Command)
 
-// WithLogger overrides the default logger.
+// WithLogger overrides the default logger. The logging flags have no effect
+// on the provided logger.
 func WithLogger(l cloudsql.Logger) Option {
 	return func(c *Command) {
 		c.logger = l
+		c.customLogger = true
 	}
 }
 
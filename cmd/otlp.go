@@ -0,0 +1,162 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+	ocbridge "go.opentelemetry.io/otel/bridge/opencensus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http"
+)
+
+// startOTLPExporter exports the Proxy's OpenCensus metrics and traces to an
+// OpenTelemetry collector over OTLP. It returns a function that flushes any
+// pending telemetry and stops the exporters.
+func startOTLPExporter(ctx context.Context, l cloudsql.Logger, conf *proxy.Config,
+	enableMetrics, enableTraces bool) (func(), error) {
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("cloud-sql-proxy"),
+		semconv.ServiceVersion(versionString),
+	)
+
+	var stops []func(context.Context) error
+	stop := func() {
+		// Give the exporters a few seconds to flush.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, s := range stops {
+			if err := s(ctx); err != nil {
+				l.Errorf("Failed to export to OTLP endpoint: %v", err)
+			}
+		}
+	}
+
+	if enableMetrics {
+		exp, err := newOTLPMetricExporter(ctx, conf)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		// Collect the OpenCensus metrics, which includes those of the Go
+		// connector, and export them with the OpenTelemetry SDK.
+		r := sdkmetric.NewPeriodicReader(exp,
+			sdkmetric.WithProducer(ocbridge.NewMetricProducer()))
+		mp := sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(r),
+			sdkmetric.WithResource(res),
+		)
+		stops = append(stops, mp.Shutdown)
+	}
+
+	if enableTraces {
+		exp, err := newOTLPTraceExporter(ctx, conf)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		s := sdktrace.TraceIDRatioBased(1 / float64(conf.TelemetryTracingSampleRate))
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exp),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(s)),
+		)
+		// Send the OpenCensus spans, which include those of the Go
+		// connector, through the OpenTelemetry SDK. The bridge replaces the
+		// OpenCensus tracer, so Cloud Trace no longer receives spans.
+		if conf.TelemetryProject != "" {
+			l.Infof("Exporting traces to the OTLP endpoint instead of Cloud Trace")
+		}
+		ocbridge.InstallTraceBridge(ocbridge.WithTracerProvider(tp))
+		stops = append(stops, tp.Shutdown)
+	}
+
+	return stop, nil
+}
+
+// newOTLPMetricExporter returns a metric exporter for the configured
+// protocol. Over HTTP, the endpoint is a base URL to which the signal path
+// (e.g., /v1/metrics) is appended.
+func newOTLPMetricExporter(ctx context.Context, conf *proxy.Config) (sdkmetric.Exporter, error) {
+	switch conf.OTLPProtocol {
+	case otlpProtocolGRPC:
+		return otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpointURL(conf.OTLPEndpoint),
+			otlpmetricgrpc.WithHeaders(conf.OTLPHeaders),
+		)
+	case otlpProtocolHTTP:
+		u, err := url.Parse(conf.OTLPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(u.Host),
+			otlpmetrichttp.WithURLPath(path.Join("/", u.Path, "v1/metrics")),
+			otlpmetrichttp.WithHeaders(conf.OTLPHeaders),
+		}
+		if u.Scheme == "http" {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %q", conf.OTLPProtocol)
+	}
+}
+
+// newOTLPTraceExporter returns a span exporter for the configured protocol.
+// Over HTTP, the endpoint is a base URL to which the signal path (e.g.,
+// /v1/traces) is appended.
+func newOTLPTraceExporter(ctx context.Context, conf *proxy.Config) (sdktrace.SpanExporter, error) {
+	switch conf.OTLPProtocol {
+	case otlpProtocolGRPC:
+		return otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpointURL(conf.OTLPEndpoint),
+			otlptracegrpc.WithHeaders(conf.OTLPHeaders),
+		)
+	case otlpProtocolHTTP:
+		u, err := url.Parse(conf.OTLPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(u.Host),
+			otlptracehttp.WithURLPath(path.Join("/", u.Path, "v1/traces")),
+			otlptracehttp.WithHeaders(conf.OTLPHeaders),
+		}
+		if u.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %q", conf.OTLPProtocol)
+	}
+}
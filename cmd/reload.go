@@ -0,0 +1,164 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+)
+
+// reloader re-reads the configuration file and applies any changes to the
+// instances of a running proxy.
+type reloader struct {
+	// mu ensures only one reload runs at a time.
+	mu  sync.Mutex
+	cmd *Command
+	p   *proxy.Client
+}
+
+// watch reloads the configuration on SIGHUP and, if enabled, whenever the
+// configuration file changes. It returns when ctx is done.
+func (r *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changed := make(chan struct{}, 1)
+	if r.cmd.conf.WatchConfigFile {
+		if err := watchFile(ctx, r.cmd.logger, r.cmd.conf.Filepath, changed); err != nil {
+			r.cmd.logger.Errorf("Failed to watch %v: %v", r.cmd.conf.Filepath, err)
+		}
+	}
+
+	for {
+		select {
+		case <-hup:
+			r.cmd.logger.Infof("SIGHUP signal received. Reloading %v", r.cmd.conf.Filepath)
+		case <-changed:
+			r.cmd.logger.Infof("Configuration file %v changed. Reloading", r.cmd.conf.Filepath)
+		case <-ctx.Done():
+			return
+		}
+		if err := r.reload(ctx); err != nil {
+			r.cmd.logger.Errorf("Failed to reload configuration: %v", err)
+		}
+	}
+}
+
+// watchFile signals on changed whenever the file at path is written or
+// replaced, until ctx is done. The directory is watched, so that a file
+// replaced by a rename or by updating a symlink, e.g., a Kubernetes ConfigMap,
+// is still seen.
+func watchFile(ctx context.Context, l cloudsql.Logger, path string, changed chan<- struct{}) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	file := filepath.Clean(path)
+	if err := w.Add(filepath.Dir(file)); err != nil {
+		w.Close()
+		return err
+	}
+	target, _ := filepath.EvalSymlinks(file)
+	go func() {
+		defer w.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				cur, _ := filepath.EvalSymlinks(file)
+				written := filepath.Clean(e.Name) == file && e.Has(fsnotify.Write|fsnotify.Create)
+				if !written && (cur == "" || cur == target) {
+					continue
+				}
+				target = cur
+				select {
+				case changed <- struct{}{}:
+				default:
+					// A reload is already pending.
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				l.Errorf("Error watching %v: %v", file, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// reload loads the configuration again as if the Command were invoked anew
+// with the same arguments. Changes to the instances are applied to the running
+// proxy. Any other changed settings are reported as requiring a restart.
+func (r *reloader) reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	if restart := changedFlags(r.cmd, next); len(restart) > 0 {
+		r.cmd.logger.Errorf(
+			"The following settings cannot be changed at runtime and "+
+				"require a restart of the proxy to take effect: %v", restart,
+		)
+	}
+	return r.p.UpdateInstances(ctx, next.conf.Instances)
+}
+
+// load builds a new Command from the original command line arguments and the
+// current contents of the configuration file.
+func (r *reloader) load() (*Command, error) {
+	next := NewCommand(r.cmd.opts...)
+	next.logger = r.cmd.logger
+	if err := next.ParseFlags(r.cmd.cliFlags); err != nil {
+		return nil, err
+	}
+	if err := loadConfig(next, r.cmd.args, r.cmd.opts); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// changedFlags reports the names of flags whose values differ between the two
+// commands.
+func changedFlags(cur, next *Command) []string {
+	var names []string
+	next.Flags().VisitAll(func(f *pflag.Flag) {
+		c := cur.Flags().Lookup(f.Name)
+		if c == nil || c.Value.String() != f.Value.String() {
+			names = append(names, f.Name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
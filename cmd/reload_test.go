@@ -0,0 +1,194 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/log"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeConfigFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfigFile(t, path, `
+instance-connection-name-0 = "proj:region:unchanged?port=24022"
+instance-connection-name-1 = "proj:region:removed?port=24023"
+instance-connection-name-2 = "proj:region:changed?port=24024"
+`)
+	cmd, err := invokeProxyCommand([]string{"--config-file", path})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	ctx := context.Background()
+	l := log.NewStdLogger(os.Stdout, os.Stdout)
+	p, err := proxy.NewClient(ctx, &spyDialer{}, l, cmd.conf, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer p.Close()
+	go p.Serve(ctx, func() {})
+
+	writeConfigFile(t, path, `
+debug-logs = true
+instance-connection-name-0 = "proj:region:unchanged?port=24022"
+instance-connection-name-1 = "proj:region:changed?port=24025"
+instance-connection-name-2 = "proj:region:added?port=24026"
+`)
+	r := &reloader{cmd: cmd, p: p}
+	next, err := r.load()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got, want := changedFlags(cmd, next), []string{"debug-logs"}; !cmp.Equal(want, got) {
+		t.Fatalf("changed flags mismatch (-want +got):\n%v", cmp.Diff(want, got))
+	}
+	if err := r.reload(ctx); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+
+	var got []string
+	for _, i := range p.Instances() {
+		got = append(got, i.Name+" "+i.Addr)
+	}
+	sort.Strings(got)
+	want := []string{
+		"proj:region:added 127.0.0.1:24026",
+		"proj:region:changed 127.0.0.1:24025",
+		"proj:region:unchanged 127.0.0.1:24022",
+	}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("instances mismatch (-want +got):\n%v", cmp.Diff(want, got))
+	}
+}
+
+func TestWatchConfigFileRequiresConfigFile(t *testing.T) {
+	_, err := invokeProxyCommand([]string{"--watch-config-file", "proj:region:inst"})
+	if err == nil {
+		t.Fatal("want error != nil, got = nil")
+	}
+}
+
+func TestReloadKeepsPortsAndAddedInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfigFile(t, path, `
+port = 24030
+instance-connection-name-0 = "proj:region:unchanged"
+instance-connection-name-1 = "proj:region:changed"
+`)
+	cmd, err := invokeProxyCommand([]string{"--config-file", path})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	ctx := context.Background()
+	l := log.NewStdLogger(os.Stdout, os.Stdout)
+	p, err := proxy.NewClient(ctx, &spyDialer{}, l, cmd.conf, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer p.Close()
+	go p.Serve(ctx, func() {})
+	if _, err := p.AddInstance(ctx, proxy.InstanceConnConfig{Name: "proj:region:api", Port: 24035}); err != nil {
+		t.Fatalf("AddInstance error: %v", err)
+	}
+
+	writeConfigFile(t, path, `
+port = 24030
+instance-connection-name-0 = "proj:region:unchanged"
+instance-connection-name-1 = "proj:region:changed?max-connections=5"
+`)
+	r := &reloader{cmd: cmd, p: p}
+	if err := r.reload(ctx); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+
+	var got []string
+	for _, i := range p.Instances() {
+		got = append(got, i.Name+" "+i.Addr)
+	}
+	sort.Strings(got)
+	want := []string{
+		"proj:region:api 127.0.0.1:24035",
+		"proj:region:changed 127.0.0.1:24031",
+		"proj:region:unchanged 127.0.0.1:24030",
+	}
+	if !cmp.Equal(want, got) {
+		t.Fatalf("instances mismatch (-want +got):\n%v", cmp.Diff(want, got))
+	}
+}
+
+func TestReloadKeepsMapAndSliceFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfigFile(t, path, `instance-connection-name = "proj:region:inst"`)
+	cmd, err := invokeProxyCommand([]string{
+		"--config-file", path,
+		"--otlp-endpoint", "http://localhost:4318",
+		"--otlp-headers", "authorization=secret,tenant=db",
+	})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	r := &reloader{cmd: cmd}
+	next, err := r.load()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := changedFlags(cmd, next); len(got) > 0 {
+		t.Fatalf("want no changed flags, got = %v", got)
+	}
+	if want, got := cmd.conf.OTLPHeaders, next.conf.OTLPHeaders; !cmp.Equal(want, got) {
+		t.Fatalf("OTLP headers mismatch (-want +got):\n%v", cmp.Diff(want, got))
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfigFile(t, path, `debug-logs = false`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	l := log.NewStdLogger(os.Stdout, os.Stdout)
+	if err := watchFile(ctx, l, path, changed); err != nil {
+		t.Fatalf("watchFile error: %v", err)
+	}
+
+	// Other files in the directory are ignored.
+	writeConfigFile(t, filepath.Join(filepath.Dir(path), "other.toml"), `debug-logs = true`)
+	select {
+	case <-changed:
+		t.Fatal("want no change for another file")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	writeConfigFile(t, path, `debug-logs = true`)
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want a change after writing the file")
+	}
+}
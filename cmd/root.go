@@ -17,15 +17,18 @@ package cmd
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/pprof"
 	"net/url"
 	"os"
 	"os/signal"
+	"os/user"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -45,6 +48,7 @@ import (
 	"github.com/spf13/viper"
 	"go.opencensus.io/trace"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -91,9 +95,27 @@ type Command struct {
 	*cobra.Command
 	conf             *proxy.Config
 	logger           cloudsql.Logger
+	customLogger     bool
 	dialer           cloudsql.Dialer
 	cleanup          func() error
 	connRefuseNotify func()
+
+	// fuseInstanceSettings holds the per-instance settings for FUSE mode,
+	// each in the form PATTERN?QUERY.
+	fuseInstanceSettings []string
+
+	// unixSocketMode, unixSocketUID, unixSocketGID, and unixSocketGroup hold
+	// the Unix socket permissions as given on the command line.
+	unixSocketMode  string
+	unixSocketUID   int
+	unixSocketGID   int
+	unixSocketGroup string
+
+	// args, cliFlags, and opts record how the Command was invoked so the
+	// configuration can be loaded again when the configuration file changes.
+	args     []string
+	cliFlags []string
+	opts     []Option
 }
 
 var longHelp = `
@@ -171,6 +193,126 @@ Instance Level Configuration
       ./cloud-sql-proxy \
         'my-project:us-central1:my-db-server?unix-socket-path=/path/to/socket'
 
+  By default, Unix sockets allow access for user, group, and other, and are
+  named with the instance connection name. To limit access, use
+  --unix-socket-mode, --unix-socket-uid, and --unix-socket-gid or
+  --unix-socket-group. To avoid colons in socket names, use
+  --unix-socket-name-template, where {project}, {region}, {instance}, and
+  {name} are replaced with parts of the instance connection name. Postgres
+  sockets are still created as '.s.PGSQL.5432' in a directory with the
+  templated name. For example, to create /cloudsql/my-project-my-db-server
+  that only the app group may use:
+
+      ./cloud-sql-proxy --unix-socket /cloudsql \
+        --unix-socket-mode 0770 --unix-socket-group app \
+        --unix-socket-name-template '{project}-{instance}' \
+        my-project:us-central1:my-db-server
+
+  When the Proxy starts, it removes any Unix socket left behind by a process
+  that exited without cleaning up, but fails if another process still
+  accepts connections on the socket. To stop two Proxies from using the same
+  directory, set --unix-socket-lock. The Proxy then holds a lock on a
+  cloud-sql-proxy.lock file in the directory, which records its PID.
+
+  To let clients choose an instance by port, e.g., with host=/cloudsql
+  port=5433 for Postgres, set the unix-socket-port query param. The socket
+  is then created directly in the Unix socket directory and named
+  '.s.PGSQL.<port>' for Postgres or '.s.MYSQL.<port>' for MySQL, in place of
+  the usual name. Two instances may not use the same port in one directory.
+  For example:
+
+      ./cloud-sql-proxy --unix-socket /cloudsql \
+        'my-project:us-central1:my-db-server?unix-socket-port=5433' \
+        'my-project:us-central1:my-other-server?unix-socket-port=5434'
+
+  When used as a query param, max-connections limits the connections to that
+  one instance, while the --max-connections flag limits connections across
+  all instances. Connections beyond either limit are refused. To have
+  connections wait for a free slot instead, set --connection-queue-timeout or
+  the connection-queue-timeout query param, e.g.,
+
+      ./cloud-sql-proxy \
+        'my-project:us-central1:my-db-server?max-connections=10&connection-queue-timeout=5s'
+
+  By default, the Proxy makes a single attempt to connect to an instance for
+  every client connection, and gives up after 30 seconds. To ride out brief
+  disruptions, like maintenance events, use --dial-retries to retry failed
+  attempts with an exponential backoff starting at --dial-retry-backoff. Use
+  --dial-timeout to change how long each attempt may take. Errors that cannot
+  succeed on a retry, like a missing instance or permission errors, fail
+  immediately.
+
+  Proxied connections stay open until either side closes them. To close
+  abandoned connections, set --idle-timeout to the time a connection may go
+  without traffic in either direction. To rotate long-lived connections, e.g.,
+  sessions using automatic IAM database authentication, set
+  --max-connection-lifetime. Both settings are also available as query params:
+
+      ./cloud-sql-proxy \
+        'my-project:us-central1:my-db-server?idle-timeout=30m&max-connection-lifetime=1h'
+
+  The Proxy looks up the database engine of each instance with the SQL Admin
+  API to choose a default port, or to lay out a Postgres Unix socket. To start
+  listening when the Admin API is unreachable, set the engine query param to
+  postgres, mysql, or sqlserver, or set --engine-version-cache to a file where
+  the Proxy caches the engine of each instance, e.g.,
+
+      ./cloud-sql-proxy --unix-socket /cloudsql \
+        'my-project:us-central1:my-db-server?engine=postgres'
+
+  In FUSE mode, sockets are created on demand, so query params cannot be
+  appended to an instance connection name. Instead, use
+  --fuse-instance-settings to apply query params to all instances that match
+  a pattern. The pattern uses shell-style wildcards, where * matches any
+  sequence of characters. The flag may be repeated, and the first matching
+  pattern applies. The address, port, unix-socket, unix-socket-path, and
+  unix-socket-port query params are not supported. For example, to use
+  private IP for one project and automatic IAM database authentication for
+  one instance:
+
+      ./cloud-sql-proxy --fuse /cloudsql \
+        --fuse-instance-settings 'my-project:*:*?private-ip=true' \
+        --fuse-instance-settings 'my-other-project:us-central1:my-db-server?auto-iam-authn=true'
+
+  In a configuration file, use a list of settings:
+
+      fuse-instance-settings = [
+        "my-project:*:*?private-ip=true",
+        "my-other-project:us-central1:my-db-server?auto-iam-authn=true",
+      ]
+
+FUSE mode
+
+  With --fuse, the Proxy creates a socket for an instance the first time the
+  instance is looked up in the FUSE directory. By default, any instance the
+  credentials can reach may be looked up, and its socket stays until the
+  Proxy exits. To restrict lookups, use --fuse-allow and --fuse-deny with
+  instance connection name patterns, where * matches any sequence of
+  characters. Both flags may be repeated. When --fuse-allow is set, only
+  matching instances may be looked up, and --fuse-deny takes precedence over
+  --fuse-allow. To close and remove sockets that are no longer used, set
+  --fuse-idle-timeout to the time a socket may go without any open
+  connections, e.g.,
+
+      ./cloud-sql-proxy --fuse /cloudsql \
+        --fuse-allow 'my-project:*:*' \
+        --fuse-deny 'my-project:*:admin-*' \
+        --fuse-idle-timeout 1h
+
+Transaction-aware shutdown
+
+  After receiving a TERM signal, the Proxy waits up to --max-sigterm-delay
+  for open connections to close and then closes any that remain. With
+  --transaction-aware-shutdown, the Proxy follows the Postgres and MySQL
+  protocols of each connection. Connections that are idle outside of a
+  transaction are closed right away, while connections with a transaction or
+  query in progress get the full --max-sigterm-delay. Connections that use
+  TLS between the client and the database cannot be followed and always get
+  the full delay, e.g.,
+
+      ./cloud-sql-proxy --max-sigterm-delay 30s --transaction-aware-shutdown \
+        my-project:us-central1:my-db-server
+
 Health checks
 
   When enabling the --health-check flag, the Proxy will start an HTTP server
@@ -184,12 +326,82 @@ Health checks
   flag, and when the Proxy can connect to all registered instances. Otherwise,
   returns a 503 status.
 
-  - /liveness: Always returns 200 status. If this endpoint is not responding,
+  By default, /readiness does not dial the instances. To dial every instance
+  in the background and fail readiness when one is unreachable, set
+  --readiness-check-interval. An instance is unreachable after
+  --readiness-failure-threshold consecutive failed dials, and reachable again
+  after --readiness-success-threshold consecutive successful dials. With dial
+  checks enabled, /readiness responds with JSON listing each instance's last
+  successful dial and last error. To check a single instance, use the
+  instance query param, e.g.,
+
+      curl 'localhost:9090/readiness?instance=my-project:us-central1:my-db-server'
+
+  - /liveness: Returns 200 status unless one of the liveness conditions
+  below applies. If this endpoint is not responding or returns a 503 status,
   the Proxy is in a bad state and should be restarted.
 
+  By default, /liveness only fails when it is not responding. To detect a
+  Proxy that responds but can no longer serve connections, use:
+
+  - --liveness-require-serving: fail when no socket is accepting connections.
+
+  - --liveness-max-dial-failure: fail once every dial to every instance has
+  failed for the given duration, e.g., 10m.
+
+  - --liveness-max-accept-failure: fail once accepting connections on a
+  socket has failed for the given duration.
+
+  To serve the same checks with the gRPC health checking protocol, set
+  --grpc-health-port. The gRPC health server listens on --http-address and
+  reports the services "startup", "readiness", and "liveness". The empty
+  service name reports readiness, and each instance connection name reports
+  the readiness of that instance. Watch callers receive status changes as
+  they happen.
+
   To configure the address, use --http-address. To configure the port, use
   --http-port.
 
+Running under systemd
+
+  With Type=notify, the Proxy tells systemd when it is ready and when it is
+  stopping. It also reports a status with the number of instances serving and
+  the number of open connections. If the unit sets WatchdogSec, the Proxy
+  sends watchdog keepalives at half that interval, but only while the
+  liveness conditions above pass, so systemd restarts a Proxy that can no
+  longer serve connections.
+
+  The Proxy also supports socket activation. Set FileDescriptorName on each
+  socket to the instance connection name with colons replaced by periods,
+  e.g., my-project.us-central1.my-db-server. With one instance and one
+  socket, any name works. The Proxy accepts connections on the sockets
+  passed by systemd in place of its own listeners, so systemd can start the
+  Proxy when the first client connects. For example:
+
+      # cloud-sql-proxy.socket
+      [Socket]
+      ListenStream=/run/cloudsql/my-db-server
+      FileDescriptorName=my-project.us-central1.my-db-server
+
+Upgrading without downtime
+
+  To replace the Proxy binary without refusing connections, install the new
+  binary over the old one and send the running Proxy a SIGUSR2 signal when
+  --upgrade-on-sigusr2 is set, or a POST request to /upgrade when
+  --upgrade-api is set. The Proxy starts the new binary with the same
+  arguments and hands it its listeners. Once the new process has started, it
+  accepts connections on those listeners and the old process stops accepting,
+  then shuts down once its open connections finish, waiting no longer than
+  --max-sigterm-delay. If the new process fails to start, the old one keeps
+  running. Instances added with --instances-api are not carried over. Upgrades
+  are not supported in FUSE mode or on Windows.
+
+  Under systemd, the Proxy reports the new process as the main process of
+  the service. Set NotifyAccess=all so systemd accepts notifications from
+  it, and use ExecReload=/bin/kill -USR2 $MAINPID with --upgrade-on-sigusr2
+  to upgrade with systemctl reload. Listeners passed by socket activation
+  are handed over too.
+
 Service Account Impersonation
 
   The Proxy supports service account impersonation with the
@@ -275,11 +487,23 @@ Configuration using a configuration file
       debug = true
       max-connections = 5
 
+  When the Proxy receives a SIGHUP signal, it reads the configuration file
+  again and applies any changes to the instance connection names. New
+  instances start listening, removed instances stop accepting new connections
+  while their open connections finish, and instances whose query params
+  changed are restarted on the same port unless a new one is set. Instances
+  that did not change, and instances added with --instances-api, are left
+  untouched. To reload the file automatically whenever it changes, use
+  --watch-config-file.
+  Any other changed setting (e.g., credentials) is reported in the logs and
+  requires a restart of the Proxy to take effect.
+
 Localhost Admin Server
 
   The Proxy includes support for an admin server on localhost. By default,
-  the admin server is not enabled. To enable the server, pass the --debug or
-  --quitquitquit flag. This will start the server on localhost at port 9091.
+  the admin server is not enabled. To enable the server, pass the --debug,
+  --quitquitquit, --instances-api, --connections-api, --drain-api, or
+  --upgrade-api flag. This will start the server on localhost at port 9091.
   To change the port, use the --admin-port flag.
 
   When --debug is set, the admin server enables Go's profiler available at
@@ -292,6 +516,47 @@ Localhost Admin Server
   /quitquitquit. The admin server exits gracefully when it receives a GET or POST
   request at /quitquitquit.
 
+  When --instances-api is set, the admin server adds an endpoint at
+  /instances for changing the set of instances without a restart. A GET
+  request lists the mounted instances as JSON. A POST request mounts a new
+  instance given with the instance query param, e.g.,
+
+      curl -X POST 'localhost:9091/instances?instance=my-project:us-central1:my-db-server'
+
+  The instance connection name may use the same query params as on the command
+  line, in which case it must be URL encoded. A DELETE request with the
+  instance query param stops listening for new connections to that instance.
+  Connections that are already open are left to finish on their own.
+
+  When --connections-api is set, the admin server adds an endpoint at
+  /connections that lists every active connection as JSON, including its ID,
+  instance, client address, start time, and bytes sent and received. A POST
+  request to /connections/close with the id query param closes that
+  connection, e.g.,
+
+      curl -X POST 'localhost:9091/connections/close?id=42'
+
+  When --drain-api is set, the admin server adds an endpoint at /drain that
+  moves traffic away from the Proxy ahead of shutdown, e.g., from a
+  Kubernetes preStop hook. A GET or POST request to /drain fails the
+  readiness check while existing connections keep running. The following
+  query params are optional:
+
+  - stop-accepting: an instance connection name to stop accepting new
+  connections for, or "all" for every instance. May be repeated. The
+  sockets stay in place, but new connections are closed right away.
+
+  - when-idle=true: shut down once no connections are open.
+
+  - deadline: shut down after this duration, even with connections open.
+
+  For example:
+
+      curl -X POST 'localhost:9091/drain?stop-accepting=all&when-idle=true&deadline=5m'
+
+  When --upgrade-api is set, the admin server adds an endpoint at /upgrade.
+  A POST request to /upgrade upgrades the Proxy as described below.
+
 Debug logging
 
   On occasion, it can help to enable debug logging which will report on
@@ -299,6 +564,16 @@ Debug logging
 
       ./cloud-sql-proxy <INSTANCE_CONNECTION_NAME> --debug-logs
 
+Log levels and formats
+
+  The --log-level flag sets the minimum level of logged messages: debug,
+  info, warn, or error. It takes precedence over --debug-logs and --quiet.
+  The --log-format flag selects text (the default), json (the LogEntry
+  format, same as --structured-logs), or logfmt. Messages about a connection
+  include the instance, connection ID, and client address as fields, e.g.,
+
+      ./cloud-sql-proxy <INSTANCE_CONNECTION_NAME> --log-format logfmt
+
 Waiting for Startup
 
   See the wait subcommand's help for details.
@@ -511,6 +786,8 @@ func NewCommand(opts ...Option) *Command {
 
 	localFlags.StringVar(&c.conf.Filepath, "config-file", c.conf.Filepath,
 		"Path to a TOML file containing configuration options.")
+	localFlags.BoolVar(&c.conf.WatchConfigFile, "watch-config-file", false,
+		"Reload the configuration file whenever it changes (used with --config-file)")
 	localFlags.StringVar(&c.conf.OtherUserAgents, "user-agent", "",
 		"Space separated list of additional user agents, e.g. cloud-sql-proxy-operator/0.0.1")
 	localFlags.StringVarP(&c.conf.Token, "token", "t", "",
@@ -530,23 +807,57 @@ the Proxy will then pick-up automatically.`)
 	localFlags.BoolVarP(&c.conf.StructuredLogs, "structured-logs", "l", false,
 		"Enable structured logging with LogEntry format")
 	localFlags.BoolVar(&c.conf.DebugLogs, "debug-logs", false,
-		"Enable debug logging")
+		"Enable debug logging. Same as --log-level=debug.")
+	localFlags.StringVar(&c.conf.LogLevel, "log-level", "",
+		`Log messages at or above this level: debug, info, warn, or error.
+Defaults to info, or error when --quiet is set.`)
+	localFlags.StringVar(&c.conf.LogFormat, "log-format", "",
+		`Log message format: text, json (LogEntry format), or logfmt.
+Defaults to text, or json when --structured-logs is set.`)
 	localFlags.Uint64Var(&c.conf.MaxConnections, "max-connections", 0,
-		"Limit the number of connections. Default is no limit.")
+		"(*) Limit the number of connections. Default is no limit.")
+	localFlags.DurationVar(&c.conf.ConnQueueTimeout, "connection-queue-timeout", 0,
+		`(*) When max connections are reached, wait up to this long for a free
+connection before refusing new connections. Default is to refuse immediately.`)
+	localFlags.DurationVar(&c.conf.DialTimeout, "dial-timeout", 30*time.Second,
+		"(*) Time allowed for each attempt to connect to an instance.")
+	localFlags.IntVar(&c.conf.DialRetries, "dial-retries", 0,
+		`(*) Number of times to retry a failed connection to an instance. Errors
+that cannot succeed on a retry (e.g., permission errors) are not retried.`)
+	localFlags.DurationVar(&c.conf.DialRetryBackoff, "dial-retry-backoff", 500*time.Millisecond,
+		`(*) Initial delay between connection attempts. The delay doubles with
+each attempt and includes random jitter.`)
+	localFlags.DurationVar(&c.conf.IdleTimeout, "idle-timeout", 0,
+		`(*) Close connections with no traffic in either direction for this
+long. Default is no idle timeout.`)
+	localFlags.DurationVar(&c.conf.MaxConnLifetime, "max-connection-lifetime", 0,
+		`(*) Close connections once they have been open for this long.
+Default is no maximum lifetime.`)
 	localFlags.DurationVar(&c.conf.WaitBeforeClose, "min-sigterm-delay", 0,
 		"The number of seconds to accept new connections after receiving a TERM signal.")
 	localFlags.DurationVar(&c.conf.WaitOnClose, "max-sigterm-delay", 0,
 		"Maximum number of seconds to wait for connections to close after receiving a TERM signal.")
+	localFlags.BoolVar(&c.conf.TransactionAwareShutdown, "transaction-aware-shutdown", false,
+		`On shutdown, close Postgres and MySQL connections that are idle outside
+of a transaction right away, and give the rest the full --max-sigterm-delay.`)
 	localFlags.StringVar(&c.conf.TelemetryProject, "telemetry-project", "",
 		"Enable Cloud Monitoring and Cloud Trace with the provided project ID.")
 	localFlags.BoolVar(&c.conf.DisableTraces, "disable-traces", false,
-		"Disable Cloud Trace integration (used with --telemetry-project)")
+		"Disable Cloud Trace integration (used with --telemetry-project or --otlp-endpoint)")
 	localFlags.IntVar(&c.conf.TelemetryTracingSampleRate, "telemetry-sample-rate", 10_000,
-		"Set the Cloud Trace sample rate. A smaller number means more traces.")
+		"Set the Cloud Trace and OTLP trace sample rate. A smaller number means more traces.")
 	localFlags.BoolVar(&c.conf.DisableMetrics, "disable-metrics", false,
-		"Disable Cloud Monitoring integration (used with --telemetry-project)")
+		"Disable Cloud Monitoring integration (used with --telemetry-project or --otlp-endpoint)")
 	localFlags.StringVar(&c.conf.TelemetryPrefix, "telemetry-prefix", "",
 		"Prefix for Cloud Monitoring metrics.")
+	localFlags.StringVar(&c.conf.OTLPEndpoint, "otlp-endpoint", "",
+		`Enable exporting metrics and traces to an OpenTelemetry collector at the
+provided URL (e.g., http://localhost:4317). Traces are then no longer
+sent to Cloud Trace.`)
+	localFlags.StringToStringVar(&c.conf.OTLPHeaders, "otlp-headers", nil,
+		"Headers sent with every OTLP export as key=value pairs (used with --otlp-endpoint)")
+	localFlags.StringVar(&c.conf.OTLPProtocol, "otlp-protocol", otlpProtocolGRPC,
+		"Protocol used to export to the OTLP endpoint, either grpc or http (used with --otlp-endpoint)")
 	localFlags.BoolVar(&c.conf.ExitZeroOnSigterm, "exit-zero-on-sigterm", false,
 		"Exit with 0 exit code when Sigterm received (default is 143)")
 	localFlags.BoolVar(&c.conf.Prometheus, "prometheus", false,
@@ -561,10 +872,41 @@ the Proxy will then pick-up automatically.`)
 		"Enable pprof on the localhost admin server")
 	localFlags.BoolVar(&c.conf.QuitQuitQuit, "quitquitquit", false,
 		"Enable quitquitquit endpoint on the localhost admin server")
+	localFlags.BoolVar(&c.conf.InstancesAPI, "instances-api", false,
+		"Enable /instances endpoint on the localhost admin server to add and remove instances at runtime")
+	localFlags.BoolVar(&c.conf.ConnectionsAPI, "connections-api", false,
+		"Enable /connections endpoints on the localhost admin server to list and close active connections")
+	localFlags.BoolVar(&c.conf.DrainAPI, "drain-api", false,
+		"Enable /drain endpoint on the localhost admin server to fail readiness while existing connections finish")
+	localFlags.BoolVar(&c.conf.UpgradeAPI, "upgrade-api", false,
+		"Enable /upgrade endpoint on the localhost admin server to hand the listeners to a new copy of the Proxy")
+	localFlags.BoolVar(&c.conf.UpgradeOnSIGUSR2, "upgrade-on-sigusr2", false,
+		"Hand the listeners to a new copy of the Proxy when a SIGUSR2 signal is received")
 	localFlags.StringVar(&c.conf.AdminPort, adminPortFlag, "9091",
 		"Port for localhost-only admin server")
 	localFlags.BoolVar(&c.conf.HealthCheck, "health-check", false,
 		"Enables health check endpoints /startup, /liveness, and /readiness on localhost.")
+	localFlags.StringVar(&c.conf.GRPCHealthPort, "grpc-health-port", "",
+		`Port on --http-address for a gRPC health check server using the
+grpc.health.v1.Health service. Default is no gRPC health check server.`)
+	localFlags.DurationVar(&c.conf.ReadinessCheckInterval, "readiness-check-interval", 0,
+		`Dial every instance on this interval in the background and fail
+/readiness when an instance is unreachable. Default is no dial checks.`)
+	localFlags.DurationVar(&c.conf.ReadinessCheckTimeout, "readiness-check-timeout", 0,
+		`Time limit for each readiness dial check. Default is the
+--readiness-check-interval.`)
+	localFlags.IntVar(&c.conf.ReadinessFailureThreshold, "readiness-failure-threshold", 3,
+		"Consecutive failed dial checks before an instance is unreachable.")
+	localFlags.IntVar(&c.conf.ReadinessSuccessThreshold, "readiness-success-threshold", 1,
+		"Consecutive successful dial checks before an instance is reachable again.")
+	localFlags.BoolVar(&c.conf.LivenessRequireServing, "liveness-require-serving", false,
+		"Fail /liveness when no socket is accepting connections.")
+	localFlags.DurationVar(&c.conf.LivenessMaxDialFailure, "liveness-max-dial-failure", 0,
+		`Fail /liveness once every dial to every instance has failed for this
+long. Default is to ignore dial failures.`)
+	localFlags.DurationVar(&c.conf.LivenessMaxAcceptFailure, "liveness-max-accept-failure", 0,
+		`Fail /liveness once accepting connections on a socket has failed for
+this long. Default is to ignore accept failures.`)
 	localFlags.StringVar(&c.conf.APIEndpointURL, "sqladmin-api-endpoint", "",
 		"API endpoint for all Cloud SQL Admin API requests. (default: https://sqladmin.googleapis.com)")
 	localFlags.StringVar(&c.conf.UniverseDomain, "universe-domain", "",
@@ -579,10 +921,22 @@ https://cloud.google.com/storage/docs/requester-pays`)
 	localFlags.StringVar(&c.conf.FUSETempDir, "fuse-tmp-dir",
 		filepath.Join(os.TempDir(), "csql-tmp"),
 		"Temp dir for Unix sockets created with FUSE")
+	localFlags.StringArrayVar(&c.conf.FUSEAllow, "fuse-allow", nil,
+		`Only allow FUSE lookups of instances matching the pattern, e.g.,
+'my-project:*:*'. May be repeated.`)
+	localFlags.StringArrayVar(&c.conf.FUSEDeny, "fuse-deny", nil,
+		"Reject FUSE lookups of instances matching the pattern. May be repeated.")
+	localFlags.DurationVar(&c.conf.FUSEIdleTimeout, "fuse-idle-timeout", 0,
+		`Close and remove a FUSE socket after it has had no connections for
+this long. Default is to keep sockets until shutdown.`)
+	localFlags.StringArrayVar(&c.fuseInstanceSettings, "fuse-instance-settings", nil,
+		`Query params to apply to FUSE instances matching a pattern, e.g.,
+'my-project:*:*?private-ip=true'. May be repeated.`)
 	localFlags.StringVar(&c.conf.ImpersonationChain, "impersonate-service-account", "",
 		`Comma separated list of service accounts to impersonate. Last value
 is the target account.`)
-	localFlags.BoolVar(&c.conf.Quiet, "quiet", false, "Log error messages only")
+	localFlags.BoolVar(&c.conf.Quiet, "quiet", false,
+		"Log error messages only. Same as --log-level=error.")
 	localFlags.BoolVar(&c.conf.AutoIP, "auto-ip", false,
 		`Supports legacy behavior of v1 and will try to connect to first IP
 address returned by the SQL Admin API. In most cases, this flag should not be used.
@@ -606,7 +960,26 @@ status code.`)
 
 	localFlags.BoolVar(&c.conf.SkipFailedInstanceConfig, "skip-failed-instance-config", false,
 		`If set, the Proxy will skip any instances that are invalid/unreachable (
-only applicable to Unix sockets)`)
+only applicable to Unix sockets). Skipped instances are retried in the background.`)
+	localFlags.StringVar(&c.conf.EngineVersionCache, "engine-version-cache", "",
+		`Path to a file that caches the database engine of each instance, so
+the Proxy can start listening without calling the SQL Admin API.`)
+	localFlags.StringVar(&c.unixSocketMode, "unix-socket-mode", "",
+		`File mode of Unix sockets in octal, e.g., 0770. Default is to allow
+access for user, group, and other.`)
+	localFlags.IntVar(&c.unixSocketUID, "unix-socket-uid", -1,
+		"User ID to own Unix sockets. Default is the user running the Proxy.")
+	localFlags.IntVar(&c.unixSocketGID, "unix-socket-gid", -1,
+		"Group ID to own Unix sockets. Default is the group of the Proxy.")
+	localFlags.StringVar(&c.unixSocketGroup, "unix-socket-group", "",
+		"Name of the group to own Unix sockets. Cannot be used with --unix-socket-gid.")
+	localFlags.StringVar(&c.conf.UnixSocketNameTemplate, "unix-socket-name-template", "",
+		`Name of Unix sockets, where {project}, {region}, {instance}, and {name}
+are replaced with parts of the instance connection name, e.g.,
+{project}-{instance}. Default is the instance connection name.`)
+	localFlags.BoolVar(&c.conf.UnixSocketLock, "unix-socket-lock", false,
+		`Lock the --unix-socket directory with a cloud-sql-proxy.lock file holding
+the Proxy's PID, so a second Proxy cannot use the same directory.`)
 
 	// Global and per instance flags
 	localFlags.StringVarP(&c.conf.Addr, "address", "a", "127.0.0.1",
@@ -635,11 +1008,20 @@ func loadConfig(c *Command, args []string, opts []Option) error {
 		return err
 	}
 
+	c.args, c.opts, c.cliFlags = args, opts, nil
+	c.Flags().Visit(func(f *pflag.Flag) {
+		c.cliFlags = append(c.cliFlags, flagArgs(f)...)
+	})
+
 	c.Flags().VisitAll(func(f *pflag.Flag) {
 		// Override any unset flags with Viper values to use the pflags
 		// object as a single source of truth.
 		if !f.Changed {
-			if v.IsSet(f.Name) {
+			if sv, ok := f.Value.(pflag.SliceValue); ok && v.IsSet(f.Name) {
+				// Lists, e.g., from a configuration file, set one value
+				// per element.
+				_ = sv.Replace(v.GetStringSlice(f.Name))
+			} else if v.IsSet(f.Name) {
 				val := v.Get(f.Name)
 				_ = c.Flags().Set(f.Name, fmt.Sprintf("%v", val))
 			} else if f.Name == "sqldata-api-endpoint" && v.IsSet("sql-data-endpoint") {
@@ -663,13 +1045,14 @@ func loadConfig(c *Command, args []string, opts []Option) error {
 		o(c)
 	}
 
-	// Handle logger separately from config
-	if c.conf.StructuredLogs {
-		c.logger = log.NewStructuredLogger(c.conf.Quiet)
-	}
-
-	if c.conf.Quiet {
-		c.logger = log.NewStdLogger(io.Discard, os.Stderr)
+	// Handle logger separately from config. A logger provided with
+	// WithLogger is used as is.
+	if !c.customLogger {
+		l, err := newLogger(c.conf)
+		if err != nil {
+			return err
+		}
+		c.logger = l
 	}
 
 	err = parseConfig(c, c.conf, args)
@@ -686,6 +1069,43 @@ func loadConfig(c *Command, args []string, opts []Option) error {
 	return nil
 }
 
+// newLogger creates a logger from the logging flags. --log-level takes
+// precedence over --debug-logs and --quiet, and --log-format takes precedence
+// over --structured-logs.
+func newLogger(conf *proxy.Config) (cloudsql.Logger, error) {
+	level := conf.LogLevel
+	switch {
+	case level != "":
+	case conf.DebugLogs:
+		level = "debug"
+	case conf.Quiet:
+		level = "error"
+	default:
+		level = "info"
+	}
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return nil, newBadCommandError(err.Error())
+	}
+	if lvl <= slog.LevelDebug {
+		// Include debug messages from the dialer too.
+		conf.DebugLogs = true
+	}
+
+	format := conf.LogFormat
+	if format == "" {
+		format = log.FormatText
+		if conf.StructuredLogs {
+			format = log.FormatJSON
+		}
+	}
+	l, err := log.NewLogger(os.Stdout, os.Stderr, format, lvl)
+	if err != nil {
+		return nil, newBadCommandError(err.Error())
+	}
+	return l, nil
+}
+
 func initViper(c *Command) (*viper.Viper, error) {
 	v := viper.New()
 
@@ -792,6 +1212,27 @@ func userHasSetGlobal(cmd *Command, f string) bool {
 	return cmd.PersistentFlags().Lookup(f).Changed
 }
 
+// flagArgs returns the command line arguments that set the flag to its
+// current value.
+func flagArgs(f *pflag.Flag) []string {
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		var args []string
+		for _, v := range sv.GetSlice() {
+			args = append(args, fmt.Sprintf("--%s=%s", f.Name, v))
+		}
+		return args
+	}
+	if f.Value.Type() == "stringToString" {
+		// Maps print as [k=v,...] but are set without the brackets.
+		v := strings.TrimSuffix(strings.TrimPrefix(f.Value.String(), "["), "]")
+		if v == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("--%s=%s", f.Name, v)}
+	}
+	return []string{fmt.Sprintf("--%s=%s", f.Name, f.Value)}
+}
+
 func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 	// If no instance connection names were provided AND FUSE isn't enabled,
 	// error.
@@ -803,6 +1244,12 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 		if conf.RunConnectionTest {
 			return newBadCommandError("cannot run connection tests in FUSE mode")
 		}
+		if conf.UpgradeAPI {
+			return newBadCommandError("cannot use --upgrade-api in FUSE mode")
+		}
+		if conf.UpgradeOnSIGUSR2 {
+			return newBadCommandError("cannot use --upgrade-on-sigusr2 in FUSE mode")
+		}
 
 		if err := proxy.SupportsFUSE(); err != nil {
 			return newBadCommandError(
@@ -814,6 +1261,26 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 	if len(args) == 0 && conf.FUSEDir == "" && conf.FUSETempDir != "" {
 		return newBadCommandError("cannot specify --fuse-tmp-dir without --fuse")
 	}
+	if conf.FUSEDir == "" && len(cmd.fuseInstanceSettings) > 0 {
+		return newBadCommandError("cannot specify --fuse-instance-settings without --fuse")
+	}
+	if conf.FUSEDir == "" && (len(conf.FUSEAllow) > 0 || len(conf.FUSEDeny) > 0 || conf.FUSEIdleTimeout != 0) {
+		return newBadCommandError("cannot specify --fuse-allow, --fuse-deny, or --fuse-idle-timeout without --fuse")
+	}
+	if conf.FUSEIdleTimeout < 0 {
+		return newBadCommandError("--fuse-idle-timeout must not be negative")
+	}
+	for _, patterns := range [][]string{conf.FUSEAllow, conf.FUSEDeny} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return newBadCommandError(fmt.Sprintf("--fuse-allow or --fuse-deny has an invalid pattern: %q", p))
+			}
+		}
+	}
+
+	if conf.WatchConfigFile && conf.Filepath == "" {
+		return newBadCommandError("cannot specify --watch-config-file without --config-file")
+	}
 
 	if userHasSetLocal(cmd, "address") && userHasSetLocal(cmd, "unix-socket") {
 		return newBadCommandError("cannot specify --unix-socket and --address together")
@@ -821,6 +1288,17 @@ func parseConfig(cmd *Command, conf *proxy.Config, args []string) error {
 	if userHasSetLocal(cmd, "port") && userHasSetLocal(cmd, "unix-socket") {
 		return newBadCommandError("cannot specify --unix-socket and --port together")
 	}
+	if conf.UnixSocketLock && conf.UnixSocket == "" {
+		return newBadCommandError("cannot specify --unix-socket-lock without --unix-socket")
+	}
+	if err := parseUnixSocketPerms(cmd, conf); err != nil {
+		return err
+	}
+	if conf.UnixSocketNameTemplate != "" {
+		if _, err := proxy.UnixSocketName(conf.UnixSocketNameTemplate, "project:region:instance"); err != nil {
+			return newBadCommandError(fmt.Sprintf("invalid --unix-socket-name-template: %v", err))
+		}
+	}
 	if ip := net.ParseIP(conf.Addr); ip == nil {
 		return newBadCommandError(fmt.Sprintf("not a valid IP address: %q", conf.Addr))
 	}
@@ -881,19 +1359,47 @@ and re-try with just --auto-iam-authn`)
 		cmd.logger.Infof("Ignoring --http-port because --prometheus or --health-check was not set")
 	}
 
+	if conf.ReadinessCheckInterval < 0 || conf.ReadinessCheckTimeout < 0 {
+		return newBadCommandError("--readiness-check-interval and --readiness-check-timeout must not be negative")
+	}
+	if conf.ReadinessFailureThreshold < 1 || conf.ReadinessSuccessThreshold < 1 {
+		return newBadCommandError("--readiness-failure-threshold and --readiness-success-threshold must be at least 1")
+	}
+	if conf.LivenessMaxDialFailure < 0 || conf.LivenessMaxAcceptFailure < 0 {
+		return newBadCommandError("--liveness-max-dial-failure and --liveness-max-accept-failure must not be negative")
+	}
+	if userHasSetLocal(cmd, "readiness-check-interval") && !conf.HealthCheck && conf.GRPCHealthPort == "" {
+		cmd.logger.Infof("Ignoring --readiness-check-interval because --health-check or --grpc-health-port was not set")
+	}
+
 	if !userHasSetLocal(cmd, "telemetry-project") && userHasSetLocal(cmd, "telemetry-prefix") {
 		cmd.logger.Infof("Ignoring --telementry-prefix because --telemetry-project was not set")
 	}
-	if !userHasSetLocal(cmd, "telemetry-project") && userHasSetLocal(cmd, "disable-metrics") {
-		cmd.logger.Infof("Ignoring --disable-metrics because --telemetry-project was not set")
+	telemetrySet := userHasSetLocal(cmd, "telemetry-project") || userHasSetLocal(cmd, "otlp-endpoint")
+	if !telemetrySet && userHasSetLocal(cmd, "disable-metrics") {
+		cmd.logger.Infof("Ignoring --disable-metrics because --telemetry-project or --otlp-endpoint was not set")
 	}
-	if !userHasSetLocal(cmd, "telemetry-project") && userHasSetLocal(cmd, "disable-traces") {
-		cmd.logger.Infof("Ignoring --disable-traces because --telemetry-project was not set")
+	if !telemetrySet && userHasSetLocal(cmd, "disable-traces") {
+		cmd.logger.Infof("Ignoring --disable-traces because --telemetry-project or --otlp-endpoint was not set")
+	}
+	if conf.OTLPEndpoint != "" {
+		u, err := url.Parse(conf.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return newBadCommandError(fmt.Sprintf(
+				"the value provided for --otlp-endpoint is not a valid http or https URL, %v",
+				conf.OTLPEndpoint,
+			))
+		}
+		if conf.OTLPProtocol != otlpProtocolGRPC && conf.OTLPProtocol != otlpProtocolHTTP {
+			return newBadCommandError(fmt.Sprintf(
+				"--otlp-protocol must be either %v or %v, got %v",
+				otlpProtocolGRPC, otlpProtocolHTTP, conf.OTLPProtocol,
+			))
+		}
 	}
 
 	if userHasSetLocal(cmd, "user-agent") {
-		userAgent += " " + cmd.conf.OtherUserAgents
-		conf.UserAgent = userAgent
+		conf.UserAgent = userAgent + " " + cmd.conf.OtherUserAgents
 	}
 
 	if userHasSetLocal(cmd, "sqladmin-api-endpoint") && userHasSetLocal(cmd, "universe-domain") {
@@ -916,119 +1422,323 @@ and re-try with just --auto-iam-authn`)
 
 	var ics []proxy.InstanceConnConfig
 	for _, a := range args {
-		// Assume no query params initially
-		ic := proxy.InstanceConnConfig{
-			Name: a,
-		}
-		// If there are query params, update instance config.
-		if res := strings.SplitN(a, "?", 2); len(res) > 1 {
-			ic.Name = res[0]
-			q, err := url.ParseQuery(res[1])
-			if err != nil {
-				return newBadCommandError(fmt.Sprintf("could not parse query: %q", res[1]))
-			}
+		ic, err := parseInstanceConnConfig(conf, a)
+		if err != nil {
+			return err
+		}
+		ics = append(ics, ic)
+	}
 
-			a, aok := q["address"]
-			p, pok := q["port"]
-			u, uok := q["unix-socket"]
-			up, upok := q["unix-socket-path"]
-			sd, sdok := q["sql-data"]
+	conf.Instances = ics
+	if err := checkUnixSocketPorts(conf); err != nil {
+		return err
+	}
 
-			if aok && uok {
-				return newBadCommandError("cannot specify both address and unix-socket query params")
-			}
-			if pok && uok {
-				return newBadCommandError("cannot specify both port and unix-socket query params")
-			}
-			if aok && upok {
-				return newBadCommandError("cannot specify both address and unix-socket-path query params")
-			}
-			if pok && upok {
-				return newBadCommandError("cannot specify both port and unix-socket-path query params")
+	var rules []proxy.FUSEInstanceRule
+	for _, a := range cmd.fuseInstanceSettings {
+		r, err := parseFUSEInstanceRule(conf, a)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, r)
+	}
+	conf.FUSEInstanceRules = rules
+	return nil
+}
+
+// checkUnixSocketPorts reports an error if two instances choose the same
+// Unix socket port in the same directory.
+func checkUnixSocketPorts(conf *proxy.Config) error {
+	seen := make(map[string]string)
+	for _, ic := range conf.Instances {
+		if ic.UnixSocketPort == 0 {
+			continue
+		}
+		// The --unix-socket directory takes precedence over the query param.
+		dir := conf.UnixSocket
+		if dir == "" {
+			dir = ic.UnixSocket
+		}
+		k := fmt.Sprintf("%v:%d", filepath.Clean(dir), ic.UnixSocketPort)
+		if other, ok := seen[k]; ok {
+			return newBadCommandError(fmt.Sprintf(
+				"%v and %v cannot both use unix-socket-port %d in %v",
+				other, ic.Name, ic.UnixSocketPort, dir,
+			))
+		}
+		seen[k] = ic.Name
+	}
+	return nil
+}
+
+// parseUnixSocketPerms validates the Unix socket mode and ownership flags and
+// sets them on the configuration.
+func parseUnixSocketPerms(cmd *Command, conf *proxy.Config) error {
+	if cmd.unixSocketMode != "" {
+		m, err := strconv.ParseUint(cmd.unixSocketMode, 8, 32)
+		if err != nil || m == 0 || m > 0777 {
+			return newBadCommandError(fmt.Sprintf(
+				"--unix-socket-mode should be an octal file mode, e.g., 0770, got: %q",
+				cmd.unixSocketMode,
+			))
+		}
+		conf.UnixSocketMode = os.FileMode(m)
+	}
+	if userHasSetLocal(cmd, "unix-socket-uid") {
+		if cmd.unixSocketUID < 0 {
+			return newBadCommandError(fmt.Sprintf("--unix-socket-uid should be non-negative, got: %v", cmd.unixSocketUID))
+		}
+		uid := cmd.unixSocketUID
+		conf.UnixSocketUID = &uid
+	}
+	if userHasSetLocal(cmd, "unix-socket-gid") && userHasSetLocal(cmd, "unix-socket-group") {
+		return newBadCommandError("cannot specify --unix-socket-gid and --unix-socket-group together")
+	}
+	if userHasSetLocal(cmd, "unix-socket-gid") {
+		if cmd.unixSocketGID < 0 {
+			return newBadCommandError(fmt.Sprintf("--unix-socket-gid should be non-negative, got: %v", cmd.unixSocketGID))
+		}
+		gid := cmd.unixSocketGID
+		conf.UnixSocketGID = &gid
+	}
+	if cmd.unixSocketGroup != "" {
+		g, err := user.LookupGroup(cmd.unixSocketGroup)
+		if err != nil {
+			return newBadCommandError(fmt.Sprintf("--unix-socket-group is not a valid group: %v", err))
+		}
+		gid, err := strconv.Atoi(g.Gid)
+		if err != nil {
+			return newBadCommandError(fmt.Sprintf("--unix-socket-group does not have a numeric group ID: %q", g.Gid))
+		}
+		conf.UnixSocketGID = &gid
+	}
+	return nil
+}
+
+// parseFUSEInstanceRule parses an instance connection name pattern with query
+// params into a rule for instances created in FUSE mode.
+func parseFUSEInstanceRule(conf *proxy.Config, a string) (proxy.FUSEInstanceRule, error) {
+	if !strings.Contains(a, "?") {
+		return proxy.FUSEInstanceRule{}, newBadCommandError(fmt.Sprintf(
+			"--fuse-instance-settings should be a pattern with query params, got: %q", a,
+		))
+	}
+	ic, err := parseInstanceConnConfig(conf, a)
+	if err != nil {
+		return proxy.FUSEInstanceRule{}, err
+	}
+	if _, err := path.Match(ic.Name, ""); err != nil {
+		return proxy.FUSEInstanceRule{}, newBadCommandError(fmt.Sprintf(
+			"--fuse-instance-settings has an invalid pattern: %q", ic.Name,
+		))
+	}
+	if ic.Addr != "" || ic.Port != 0 || ic.UnixSocket != "" || ic.UnixSocketPath != "" || ic.UnixSocketPort != 0 {
+		return proxy.FUSEInstanceRule{}, newBadCommandError(
+			"the address, port, unix-socket, unix-socket-path, and unix-socket-port " +
+				"query params are not supported with --fuse-instance-settings",
+		)
+	}
+	return proxy.FUSEInstanceRule{Pattern: ic.Name, Settings: ic}, nil
+}
+
+// parseInstanceConnConfig parses an instance connection name with optional
+// query params into an instance configuration.
+func parseInstanceConnConfig(conf *proxy.Config, a string) (proxy.InstanceConnConfig, error) {
+	// Assume no query params initially
+	ic := proxy.InstanceConnConfig{
+		Name: a,
+	}
+	// If there are query params, update instance config.
+	if res := strings.SplitN(a, "?", 2); len(res) > 1 {
+		ic.Name = res[0]
+		q, err := url.ParseQuery(res[1])
+		if err != nil {
+			return ic, newBadCommandError(fmt.Sprintf("could not parse query: %q", res[1]))
+		}
+
+		a, aok := q["address"]
+		p, pok := q["port"]
+		u, uok := q["unix-socket"]
+		up, upok := q["unix-socket-path"]
+		us, usok := q["unix-socket-port"]
+		sd, sdok := q["sql-data"]
+
+		if aok && uok {
+			return ic, newBadCommandError("cannot specify both address and unix-socket query params")
+		}
+		if pok && uok {
+			return ic, newBadCommandError("cannot specify both port and unix-socket query params")
+		}
+		if aok && upok {
+			return ic, newBadCommandError("cannot specify both address and unix-socket-path query params")
+		}
+		if pok && upok {
+			return ic, newBadCommandError("cannot specify both port and unix-socket-path query params")
+		}
+		if uok && upok {
+			return ic, newBadCommandError("cannot specify both unix-socket-path and unix-socket query params")
+		}
+		if usok && (aok || pok || upok) {
+			return ic, newBadCommandError(
+				"cannot specify unix-socket-port with the address, port, or unix-socket-path query params",
+			)
+		}
+
+		if aok {
+			if len(a) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("address query param should be only one value: %q", a))
 			}
-			if uok && upok {
-				return newBadCommandError("cannot specify both unix-socket-path and unix-socket query params")
+			if ip := net.ParseIP(a[0]); ip == nil {
+				return ic, newBadCommandError(
+					fmt.Sprintf("address query param is not a valid IP address: %q",
+						a[0],
+					))
 			}
+			ic.Addr = a[0]
+		}
 
-			if aok {
-				if len(a) != 1 {
-					return newBadCommandError(fmt.Sprintf("address query param should be only one value: %q", a))
-				}
-				if ip := net.ParseIP(a[0]); ip == nil {
-					return newBadCommandError(
-						fmt.Sprintf("address query param is not a valid IP address: %q",
-							a[0],
-						))
-				}
-				ic.Addr = a[0]
+		if pok {
+			if len(p) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("port query param should be only one value: %q", a))
+			}
+			pp, err := strconv.Atoi(p[0])
+			if err != nil {
+				return ic, newBadCommandError(
+					fmt.Sprintf("port query param is not a valid integer: %q",
+						p[0],
+					))
 			}
+			ic.Port = pp
+		}
 
-			if pok {
-				if len(p) != 1 {
-					return newBadCommandError(fmt.Sprintf("port query param should be only one value: %q", a))
-				}
-				pp, err := strconv.Atoi(p[0])
-				if err != nil {
-					return newBadCommandError(
-						fmt.Sprintf("port query param is not a valid integer: %q",
-							p[0],
-						))
-				}
-				ic.Port = pp
+		if uok {
+			if len(u) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("unix query param should be only one value: %q", a))
 			}
+			ic.UnixSocket = u[0]
+		}
 
-			if uok {
-				if len(u) != 1 {
-					return newBadCommandError(fmt.Sprintf("unix query param should be only one value: %q", a))
-				}
-				ic.UnixSocket = u[0]
+		if upok {
+			if len(up) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("unix-socket-path query param should be only one value: %q", a))
 			}
+			ic.UnixSocketPath = up[0]
+		}
 
-			if upok {
-				if len(up) != 1 {
-					return newBadCommandError(fmt.Sprintf("unix-socket-path query param should be only one value: %q", a))
-				}
-				ic.UnixSocketPath = up[0]
+		if usok {
+			if len(us) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("unix-socket-port query param should be only one value: %q", us))
 			}
-			if sdok {
-				if len(sd) != 1 {
-					return newBadCommandError(fmt.Sprintf("sql-data query param should be only one value %q", a))
-				}
-				if sd[0] != "true" && sd[0] != "false" {
-					return newBadCommandError(fmt.Sprintf("sql-data query param should be \"true\" or \"false\" %q", a))
-				}
-				b := sd[0] == "true"
-				ic.SQLDataEnabled = &b
+			pp, err := strconv.Atoi(us[0])
+			if err != nil || pp < 1 || pp > 65535 {
+				return ic, newBadCommandError(
+					fmt.Sprintf("unix-socket-port query param is not a valid port: %q",
+						us[0],
+					))
 			}
-
-			ic.IAMAuthN, err = parseBoolOpt(q, "auto-iam-authn")
-			if err != nil {
-				return err
+			if conf.UnixSocket == "" && ic.UnixSocket == "" && conf.FUSEDir == "" {
+				return ic, newBadCommandError("unix-socket-port query param requires --unix-socket or the unix-socket query param")
 			}
-
-			ic.PrivateIP, err = parseBoolOpt(q, "private-ip")
-			if err != nil {
-				return err
+			ic.UnixSocketPort = pp
+		}
+		if sdok {
+			if len(sd) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("sql-data query param should be only one value %q", a))
 			}
-			if ic.PrivateIP != nil && *ic.PrivateIP && conf.AutoIP {
-				return newBadCommandError("cannot use --auto-ip with private-ip")
+			if sd[0] != "true" && sd[0] != "false" {
+				return ic, newBadCommandError(fmt.Sprintf("sql-data query param should be \"true\" or \"false\" %q", a))
 			}
+			b := sd[0] == "true"
+			ic.SQLDataEnabled = &b
+		}
+
+		ic.IAMAuthN, err = parseBoolOpt(q, "auto-iam-authn")
+		if err != nil {
+			return ic, err
+		}
 
-			ic.PSC, err = parseBoolOpt(q, "psc")
+		ic.PrivateIP, err = parseBoolOpt(q, "private-ip")
+		if err != nil {
+			return ic, err
+		}
+		if ic.PrivateIP != nil && *ic.PrivateIP && conf.AutoIP {
+			return ic, newBadCommandError("cannot use --auto-ip with private-ip")
+		}
+
+		if mc, ok := q["max-connections"]; ok {
+			if len(mc) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("max-connections query param should be only one value: %q", mc))
+			}
+			n, err := strconv.ParseUint(mc[0], 10, 64)
 			if err != nil {
-				return err
+				return ic, newBadCommandError(
+					fmt.Sprintf("max-connections query param is not a valid integer: %q",
+						mc[0],
+					))
 			}
+			ic.MaxConnections = n
+		}
+
+		ic.ConnQueueTimeout, err = parseDurationOpt(q, "connection-queue-timeout")
+		if err != nil {
+			return ic, err
+		}
+
+		ic.DialTimeout, err = parseDurationOpt(q, "dial-timeout")
+		if err != nil {
+			return ic, err
+		}
 
-			if ic.PrivateIP != nil && ic.PSC != nil {
-				return newBadCommandError("cannot specify both private-ip and psc query params")
+		ic.DialRetries, err = parseIntOpt(q, "dial-retries")
+		if err != nil {
+			return ic, err
+		}
+
+		ic.DialRetryBackoff, err = parseDurationOpt(q, "dial-retry-backoff")
+		if err != nil {
+			return ic, err
+		}
+
+		ic.IdleTimeout, err = parseDurationOpt(q, "idle-timeout")
+		if err != nil {
+			return ic, err
+		}
+
+		ic.MaxConnLifetime, err = parseDurationOpt(q, "max-connection-lifetime")
+		if err != nil {
+			return ic, err
+		}
+
+		ic.PSC, err = parseBoolOpt(q, "psc")
+		if err != nil {
+			return ic, err
+		}
+
+		if e, ok := q["engine"]; ok {
+			if len(e) != 1 {
+				return ic, newBadCommandError(fmt.Sprintf("engine query param should be only one value: %q", e))
 			}
+			switch strings.ToLower(e[0]) {
+			case "postgres":
+				ic.Engine = "POSTGRES"
+			case "mysql":
+				ic.Engine = "MYSQL"
+			case "sqlserver":
+				ic.Engine = "SQLSERVER"
+			default:
+				return ic, newBadCommandError(
+					fmt.Sprintf("engine query param should be postgres, mysql, or sqlserver, got: %q",
+						e[0],
+					))
+			}
+		}
 
+		if ic.PrivateIP != nil && ic.PSC != nil {
+			return ic, newBadCommandError("cannot specify both private-ip and psc query params")
 		}
-		ics = append(ics, ic)
-	}
 
-	conf.Instances = ics
-	return nil
+	}
+	return ic, nil
 }
 
 // parseBoolOpt parses a boolean option from the query string, returning
@@ -1062,6 +1772,48 @@ func parseBoolOpt(q url.Values, name string) (*bool, error) {
 
 }
 
+// parseDurationOpt parses a duration option from the query string, e.g., "10s".
+func parseDurationOpt(q url.Values, name string) (*time.Duration, error) {
+	v, ok := q[name]
+	if !ok {
+		return nil, nil
+	}
+
+	if len(v) != 1 {
+		return nil, newBadCommandError(fmt.Sprintf("%v param should be only one value: %q", name, v))
+	}
+
+	d, err := time.ParseDuration(v[0])
+	if err != nil {
+		return nil, newBadCommandError(
+			fmt.Sprintf("%v query param is not a valid duration: %q",
+				name, v[0],
+			))
+	}
+	return &d, nil
+}
+
+// parseIntOpt parses a non-negative integer option from the query string.
+func parseIntOpt(q url.Values, name string) (*int, error) {
+	v, ok := q[name]
+	if !ok {
+		return nil, nil
+	}
+
+	if len(v) != 1 {
+		return nil, newBadCommandError(fmt.Sprintf("%v param should be only one value: %q", name, v))
+	}
+
+	i, err := strconv.Atoi(v[0])
+	if err != nil || i < 0 {
+		return nil, newBadCommandError(
+			fmt.Sprintf("%v query param is not a valid non-negative integer: %q",
+				name, v[0],
+			))
+	}
+	return &i, nil
+}
+
 // runSignalWrapper watches for SIGTERM and SIGINT and interupts execution if necessary.
 func runSignalWrapper(cmd *Command) (err error) {
 	defer func() { _ = cmd.cleanup() }()
@@ -1099,6 +1851,13 @@ func runSignalWrapper(cmd *Command) (err error) {
 			sd.StopMetricsExporter()
 		}()
 	}
+	if cmd.conf.OTLPEndpoint != "" && (enableMetrics || enableTraces) {
+		stop, err := startOTLPExporter(ctx, cmd.logger, cmd.conf, enableMetrics, enableTraces)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
 
 	shutdownCh := make(chan error)
 	// watch for sigterm / sigint signals
@@ -1126,6 +1885,30 @@ func runSignalWrapper(cmd *Command) (err error) {
 		}
 	}()
 
+	// Use any listeners passed by systemd socket activation.
+	lns, err := activatedListeners(cmd.conf)
+	if err != nil {
+		return fmt.Errorf("unable to use activated listeners: %v", err)
+	}
+	// Use any listeners handed over by the process being upgraded. Those it
+	// was passed by socket activation are treated as if they were passed to
+	// this process.
+	inherited, inheritedActivated, ready, err := inheritedListeners()
+	if err != nil {
+		return fmt.Errorf("unable to use inherited listeners: %v", err)
+	}
+	if ready != nil {
+		defer ready.Close()
+	}
+	for inst, l := range inheritedActivated {
+		if lns == nil {
+			lns = make(map[string]net.Listener)
+		}
+		lns[inst] = l
+	}
+	cmd.conf.Listeners = lns
+	cmd.conf.InheritedListeners = inherited
+
 	// Start the proxy asynchronously, so we can exit early if a shutdown signal is sent
 	startCh := make(chan *proxy.Client)
 	go func() {
@@ -1153,6 +1936,12 @@ func runSignalWrapper(cmd *Command) (err error) {
 		return err
 	case p = <-startCh:
 		cmd.logger.Infof("The proxy has started successfully and is ready for new connections!")
+		// Report to the process being upgraded that it can stop accepting.
+		if ready != nil {
+			if _, err := ready.Write([]byte{1}); err != nil {
+				cmd.logger.Errorf("Failed to notify the upgraded process of readiness: %v", err)
+			}
+		}
 		// If running under systemd with Type=notify, it will send a message to the
 		// service manager that it is ready to handle connections now.
 		go func() {
@@ -1169,6 +1958,20 @@ func runSignalWrapper(cmd *Command) (err error) {
 		}
 	}()
 
+	// Reload the instances from the configuration file on SIGHUP or when the
+	// file changes.
+	if cmd.conf.Filepath != "" && cmd.conf.FUSEDir == "" {
+		r := &reloader{cmd: cmd, p: p}
+		go r.watch(ctx)
+	}
+
+	// The HTTP servers stop early after an upgrade, so the new process can
+	// bind their ports. When started by an upgrade, binding is retried until
+	// the old process lets go of the ports.
+	httpCtx, stopHTTP := context.WithCancel(ctx)
+	defer stopHTTP()
+	retryBind := ready != nil
+
 	var (
 		needsHTTPServer bool
 		mux             = http.NewServeMux()
@@ -1187,14 +1990,67 @@ func runSignalWrapper(cmd *Command) (err error) {
 		mux.Handle("/metrics", e)
 	}
 
-	if cmd.conf.HealthCheck {
-		needsHTTPServer = true
-		cmd.logger.Infof("Starting health check server at %s",
-			net.JoinHostPort(cmd.conf.HTTPAddress, cmd.conf.HTTPPort))
-		hc := healthcheck.NewCheck(p, cmd.logger)
-		mux.HandleFunc("/startup", hc.HandleStartup)
-		mux.HandleFunc("/readiness", hc.HandleReadiness)
-		mux.HandleFunc("/liveness", hc.HandleLiveness)
+	// When running under systemd, report the status and send watchdog
+	// keepalives if the unit sets WatchdogSec.
+	watchdog, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		cmd.logger.Errorf("Failed to read the systemd watchdog settings: %v", err)
+	}
+	underSystemd := os.Getenv("NOTIFY_SOCKET") != ""
+	// The systemd notifications stop when the Proxy is upgraded, so they do
+	// not race with those of the new main process.
+	stopSystemd := func() {}
+
+	var hc *healthcheck.Check
+	if cmd.conf.HealthCheck || cmd.conf.GRPCHealthPort != "" || underSystemd {
+		hc = healthcheck.NewCheck(p, cmd.logger)
+		if cmd.conf.ReadinessCheckInterval > 0 {
+			dctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			hc.StartDialChecks(dctx, healthcheck.DialCheckConfig{
+				Interval:         cmd.conf.ReadinessCheckInterval,
+				Timeout:          cmd.conf.ReadinessCheckTimeout,
+				FailureThreshold: cmd.conf.ReadinessFailureThreshold,
+				SuccessThreshold: cmd.conf.ReadinessSuccessThreshold,
+			})
+		}
+		hc.SetLivenessConfig(healthcheck.LivenessConfig{
+			RequireServing:   cmd.conf.LivenessRequireServing,
+			MaxDialFailure:   cmd.conf.LivenessMaxDialFailure,
+			MaxAcceptFailure: cmd.conf.LivenessMaxAcceptFailure,
+		})
+		if cmd.conf.HealthCheck {
+			needsHTTPServer = true
+			cmd.logger.Infof("Starting health check server at %s",
+				net.JoinHostPort(cmd.conf.HTTPAddress, cmd.conf.HTTPPort))
+			mux.HandleFunc("/startup", hc.HandleStartup)
+			mux.HandleFunc("/readiness", hc.HandleReadiness)
+			mux.HandleFunc("/liveness", hc.HandleLiveness)
+		}
+		if cmd.conf.GRPCHealthPort != "" {
+			addr := net.JoinHostPort(cmd.conf.HTTPAddress, cmd.conf.GRPCHealthPort)
+			cmd.logger.Infof("Starting gRPC health check server at %s", addr)
+			g := healthcheck.NewGRPCHealth(hc)
+			go g.Run(ctx, time.Second)
+			go startGRPCHealthServer(httpCtx, cmd.logger, addr, retryBind, g, shutdownCh)
+		}
+		if underSystemd {
+			interval := systemdStatusInterval
+			if watchdog > 0 {
+				interval = watchdog / 2
+			}
+			sdCtx, cancel := context.WithCancel(ctx)
+			sdDone := make(chan struct{})
+			go func() {
+				defer close(sdDone)
+				healthcheck.NewSystemd(hc, watchdog > 0).Run(sdCtx, interval)
+			}()
+			stopSystemd = func() {
+				cancel()
+				<-sdDone
+			}
+			defer stopSystemd()
+		}
 		notifyStarted = hc.NotifyStarted
 		notifyStopped = hc.NotifyStopped
 	}
@@ -1202,9 +2058,10 @@ func runSignalWrapper(cmd *Command) (err error) {
 	// Start the HTTP server if anything requiring HTTP is specified.
 	if needsHTTPServer {
 		go startHTTPServer(
-			ctx,
+			httpCtx,
 			cmd.logger,
 			net.JoinHostPort(cmd.conf.HTTPAddress, cmd.conf.HTTPPort),
+			retryBind,
 			mux,
 			shutdownCh,
 		)
@@ -1221,6 +2078,49 @@ func runSignalWrapper(cmd *Command) (err error) {
 		var quitOnce sync.Once
 		m.HandleFunc("/quitquitquit", quitquitquit(&quitOnce, shutdownCh))
 	}
+	if cmd.conf.InstancesAPI {
+		needsAdminServer = true
+		cmd.logger.Infof("Enabling instances endpoint at localhost:%v", cmd.conf.AdminPort)
+		m.HandleFunc("/instances", instances(p, cmd.conf, cmd.logger))
+	}
+	if cmd.conf.ConnectionsAPI {
+		needsAdminServer = true
+		cmd.logger.Infof("Enabling connections endpoints at localhost:%v", cmd.conf.AdminPort)
+		m.HandleFunc("/connections", connections(p))
+		m.HandleFunc("/connections/close", closeConnection(p))
+	}
+	if cmd.conf.DrainAPI {
+		needsAdminServer = true
+		cmd.logger.Infof("Enabling drain endpoint at localhost:%v", cmd.conf.AdminPort)
+		d := &drainer{
+			ctx:        ctx,
+			p:          p,
+			hc:         hc,
+			logger:     cmd.logger,
+			shutdownCh: shutdownCh,
+			fuse:       cmd.conf.FUSEDir != "",
+		}
+		m.HandleFunc("/drain", d.handle)
+	}
+	if cmd.conf.FUSEDir == "" {
+		u := &upgrader{
+			ctx:         ctx,
+			p:           p,
+			hc:          hc,
+			logger:      cmd.logger,
+			shutdownCh:  shutdownCh,
+			stopHTTP:    stopHTTP,
+			stopSystemd: stopSystemd,
+		}
+		if cmd.conf.UpgradeOnSIGUSR2 {
+			go u.watch(ctx)
+		}
+		if cmd.conf.UpgradeAPI {
+			needsAdminServer = true
+			cmd.logger.Infof("Enabling upgrade endpoint at localhost:%v", cmd.conf.AdminPort)
+			m.HandleFunc("/upgrade", u.handle)
+		}
+	}
 	if cmd.conf.Debug {
 		needsAdminServer = true
 		cmd.logger.Infof("Enabling pprof endpoints at localhost:%v", cmd.conf.AdminPort)
@@ -1233,9 +2133,10 @@ func runSignalWrapper(cmd *Command) (err error) {
 	}
 	if needsAdminServer {
 		go startHTTPServer(
-			ctx,
+			httpCtx,
 			cmd.logger,
 			net.JoinHostPort("localhost", cmd.conf.AdminPort),
+			retryBind,
 			m,
 			shutdownCh,
 		)
@@ -1261,6 +2162,10 @@ func runSignalWrapper(cmd *Command) (err error) {
 	case errors.Is(err, errQuitQuitQuit):
 		cmd.logger.Infof("/quitquitquit received request. Shutting down...")
 		time.Sleep(cmd.conf.WaitBeforeClose)
+	case errors.Is(err, errDrained):
+		cmd.logger.Infof("/drain completed. Shutting down...")
+	case errors.Is(err, errUpgraded):
+		cmd.logger.Infof("Upgrade completed. Shutting down once open connections finish...")
 	default:
 		cmd.logger.Errorf("The proxy has encountered a terminal error: %v", err)
 	}
@@ -1284,14 +2189,106 @@ func quitquitquit(quitOnce *sync.Once, shutdownCh chan<- error) http.HandlerFunc
 	}
 }
 
-func startHTTPServer(ctx context.Context, l cloudsql.Logger, addr string, mux *http.ServeMux, shutdownCh chan<- error) {
+// instances lists, adds, and removes instances on a running proxy. A GET
+// request lists the mounted instances. A POST request mounts the instance
+// named by the "instance" query param, which may include the same query params
+// as instance connection names on the command line. A DELETE request removes
+// the named instance, leaving any open connections to drain.
+func instances(p *proxy.Client, conf *proxy.Config, l cloudsql.Logger) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			rw.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(rw).Encode(p.Instances())
+		case http.MethodPost:
+			inst := req.URL.Query().Get("instance")
+			if inst == "" {
+				http.Error(rw, "missing instance query param", http.StatusBadRequest)
+				return
+			}
+			ic, err := parseInstanceConnConfig(conf, inst)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			addr, err := p.AddInstance(req.Context(), ic)
+			var mErr *proxy.MountError
+			switch {
+			case errors.As(err, &mErr):
+				// The instance is valid, but the Admin API or the listener
+				// failed.
+				http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+				return
+			case errors.Is(err, proxy.ErrInstanceMounted):
+				http.Error(rw, err.Error(), http.StatusConflict)
+				return
+			case err != nil:
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, err := rw.Write([]byte(addr.String())); err != nil {
+				l.Errorf("Failed to write /instances response: %v", err)
+			}
+		case http.MethodDelete:
+			inst := req.URL.Query().Get("instance")
+			if inst == "" {
+				http.Error(rw, "missing instance query param", http.StatusBadRequest)
+				return
+			}
+			if err := p.RemoveInstance(inst); err != nil {
+				http.Error(rw, err.Error(), http.StatusNotFound)
+				return
+			}
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// connections lists the active connections as JSON.
+func connections(p *proxy.Client) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(p.Connections())
+	}
+}
+
+// closeConnection closes the active connection given with the id query param.
+func closeConnection(p *proxy.Client) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseUint(req.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(rw, "missing or invalid id query param", http.StatusBadRequest)
+			return
+		}
+		if err := p.CloseConnection(id); err != nil {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+}
+
+func startHTTPServer(ctx context.Context, l cloudsql.Logger, addr string, retry bool, mux *http.ServeMux, shutdownCh chan<- error) {
+	ln, err := listenTCP(ctx, addr, retry)
+	if err != nil {
+		shutdownCh <- fmt.Errorf("failed to start HTTP server: %v", err)
+		return
+	}
 	server := &http.Server{
 		Addr:    addr,
 		Handler: mux,
 	}
 	// Start the HTTP server.
 	go func() {
-		err := server.ListenAndServe()
+		err := server.Serve(ln)
 		if errors.Is(err, http.ErrServerClosed) {
 			return
 		}
@@ -1309,6 +2306,25 @@ func startHTTPServer(ctx context.Context, l cloudsql.Logger, addr string, mux *h
 	}
 }
 
+// startGRPCHealthServer serves the gRPC health checking protocol on addr
+// until ctx is done.
+func startGRPCHealthServer(ctx context.Context, l cloudsql.Logger, addr string, retry bool, g *healthcheck.GRPCHealth, shutdownCh chan<- error) {
+	ln, err := listenTCP(ctx, addr, retry)
+	if err != nil {
+		shutdownCh <- fmt.Errorf("failed to start gRPC health check server: %v", err)
+		return
+	}
+	server := grpc.NewServer()
+	g.Register(server)
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			l.Errorf("gRPC health check server error: %v", err)
+		}
+	}()
+	<-ctx.Done()
+	server.Stop()
+}
+
 func formatStackdriverError(err error) string {
 	st, ok := status.FromError(err)
 	if !ok {
@@ -22,7 +22,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
 	"github.com/coreos/go-systemd/v22/daemon"
+	"github.com/google/go-cmp/cmp"
 	"github.com/spf13/cobra"
 )
 
@@ -76,6 +78,93 @@ func TestNewCommandArgumentsOnLinux(t *testing.T) {
 	}
 }
 
+func TestNewCommandWithFUSEInstanceSettings(t *testing.T) {
+	pTrue, idle := true, time.Minute
+	want := []proxy.FUSEInstanceRule{
+		{
+			Pattern:  "proj:*:*",
+			Settings: proxy.InstanceConnConfig{Name: "proj:*:*", PrivateIP: &pTrue},
+		},
+		{
+			Pattern: "other:region:inst",
+			Settings: proxy.InstanceConnConfig{
+				Name: "other:region:inst", IAMAuthN: &pTrue, IdleTimeout: &idle,
+			},
+		},
+	}
+	tcs := []struct {
+		desc string
+		args []string
+	}{
+		{
+			desc: "using the fuse-instance-settings flag",
+			args: []string{
+				"--fuse", "/cloudsql",
+				"--fuse-instance-settings", "proj:*:*?private-ip=true",
+				"--fuse-instance-settings", "other:region:inst?auto-iam-authn=true&idle-timeout=1m",
+			},
+		},
+		{
+			desc: "using a config file",
+			args: []string{"--config-file", "testdata/fuse-instance-settings.toml"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := NewCommand()
+			// Keep the test output quiet
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+			// Disable execute behavior
+			c.RunE = func(*cobra.Command, []string) error {
+				return nil
+			}
+			c.SetArgs(tc.args)
+
+			if err := c.Execute(); err != nil {
+				t.Fatalf("want error = nil, got = %v", err)
+			}
+
+			if got := c.conf.FUSEInstanceRules; !cmp.Equal(want, got) {
+				t.Fatalf("FUSEInstanceRules mismatch (-want +got):\n%v", cmp.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestNewCommandWithFUSEInstanceSettingsErrors(t *testing.T) {
+	tcs := []struct {
+		desc     string
+		settings string
+	}{
+		{desc: "missing query params", settings: "proj:*:*"},
+		{desc: "invalid pattern", settings: "proj:[:*?private-ip=true"},
+		{desc: "unsupported port", settings: "proj:*:*?port=5000"},
+		{desc: "unsupported unix-socket-path", settings: "proj:*:*?unix-socket-path=/tmp/sock"},
+		{desc: "unsupported unix-socket-port", settings: "proj:*:*?unix-socket-port=5433"},
+		{desc: "invalid query param", settings: "proj:*:*?private-ip=maybe"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := NewCommand()
+			// Keep the test output quiet
+			c.SilenceUsage = true
+			c.SilenceErrors = true
+			// Disable execute behavior
+			c.RunE = func(*cobra.Command, []string) error {
+				return nil
+			}
+			c.SetArgs([]string{
+				"--fuse", "/cloudsql", "--fuse-instance-settings", tc.settings,
+			})
+
+			if err := c.Execute(); err == nil {
+				t.Fatal("want error != nil, got = nil")
+			}
+		})
+	}
+}
+
 func TestSdNotifyOnLinux(t *testing.T) {
 	tcs := []struct {
 		desc          string
@@ -16,9 +16,12 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -28,6 +31,7 @@ import (
 	"time"
 
 	"cloud.google.com/go/cloudsqlconn"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/log"
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
 	"github.com/google/go-cmp/cmp"
 	"github.com/spf13/cobra"
@@ -66,6 +70,21 @@ func withDefaults(c *proxy.Config) *proxy.Config {
 	if c.TelemetryTracingSampleRate == 0 {
 		c.TelemetryTracingSampleRate = 10_000
 	}
+	if c.OTLPProtocol == "" {
+		c.OTLPProtocol = "grpc"
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = 30 * time.Second
+	}
+	if c.DialRetryBackoff == 0 {
+		c.DialRetryBackoff = 500 * time.Millisecond
+	}
+	if c.ReadinessFailureThreshold == 0 {
+		c.ReadinessFailureThreshold = 3
+	}
+	if c.ReadinessSuccessThreshold == 0 {
+		c.ReadinessSuccessThreshold = 1
+	}
 	return c
 }
 
@@ -336,6 +355,86 @@ func TestNewCommandArguments(t *testing.T) {
 				StructuredLogs: true,
 			}),
 		},
+		{
+			desc: "using the log level and format flags",
+			args: []string{
+				"--log-level", "warn", "--log-format", "logfmt", "proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				LogLevel:  "warn",
+				LogFormat: "logfmt",
+			}),
+		},
+		{
+			desc: "using the debug log level enables debug logs",
+			args: []string{"--log-level", "debug", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				LogLevel:  "debug",
+				DebugLogs: true,
+			}),
+		},
+		{
+			desc: "using the readiness dial check flags",
+			args: []string{
+				"--health-check",
+				"--readiness-check-interval", "30s",
+				"--readiness-check-timeout", "5s",
+				"--readiness-failure-threshold", "2",
+				"--readiness-success-threshold", "4",
+				"proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				HealthCheck:               true,
+				ReadinessCheckInterval:    30 * time.Second,
+				ReadinessCheckTimeout:     5 * time.Second,
+				ReadinessFailureThreshold: 2,
+				ReadinessSuccessThreshold: 4,
+			}),
+		},
+		{
+			desc: "using the drain API flag",
+			args: []string{"--drain-api", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				DrainAPI: true,
+			}),
+		},
+		{
+			desc: "using the upgrade API flag",
+			args: []string{"--upgrade-api", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				UpgradeAPI: true,
+			}),
+		},
+		{
+			desc: "using the upgrade on SIGUSR2 flag",
+			args: []string{"--upgrade-on-sigusr2", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				UpgradeOnSIGUSR2: true,
+			}),
+		},
+		{
+			desc: "using the gRPC health port flag",
+			args: []string{"--grpc-health-port", "9092", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				GRPCHealthPort: "9092",
+			}),
+		},
+		{
+			desc: "using the liveness flags",
+			args: []string{
+				"--health-check",
+				"--liveness-require-serving",
+				"--liveness-max-dial-failure", "10m",
+				"--liveness-max-accept-failure", "1m",
+				"proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				HealthCheck:              true,
+				LivenessRequireServing:   true,
+				LivenessMaxDialFailure:   10 * time.Minute,
+				LivenessMaxAcceptFailure: time.Minute,
+			}),
+		},
 		{
 			desc: "using the max connections flag",
 			args: []string{"--max-connections", "1", "proj:region:inst"},
@@ -357,6 +456,13 @@ func TestNewCommandArguments(t *testing.T) {
 				WaitOnClose: 10 * time.Second,
 			}),
 		},
+		{
+			desc: "using the transaction-aware-shutdown flag",
+			args: []string{"--transaction-aware-shutdown", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				TransactionAwareShutdown: true,
+			}),
+		},
 		{
 			desc: "using the private-ip flag",
 			args: []string{"--private-ip", "proj:region:inst"},
@@ -399,6 +505,73 @@ func TestNewCommandArguments(t *testing.T) {
 				}},
 			}),
 		},
+		{
+			desc: "using the engine query param",
+			args: []string{"proj:region:inst?engine=MySQL"},
+			want: withDefaults(&proxy.Config{
+				Instances: []proxy.InstanceConnConfig{{
+					Engine: "MYSQL",
+				}},
+			}),
+		},
+		{
+			desc: "using the engine version cache flag",
+			args: []string{"--engine-version-cache", "/tmp/engines.json", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				EngineVersionCache: "/tmp/engines.json",
+			}),
+		},
+		{
+			desc: "using the Unix socket permission flags",
+			args: []string{
+				"--unix-socket", "/path/to/dir/",
+				"--unix-socket-mode", "0770",
+				"--unix-socket-uid", "1000",
+				"--unix-socket-gid", "2000",
+				"proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				UnixSocket:     "/path/to/dir/",
+				UnixSocketMode: 0770,
+				UnixSocketUID:  pointer(1000),
+				UnixSocketGID:  pointer(2000),
+			}),
+		},
+		{
+			desc: "using the Unix socket lock flag",
+			args: []string{"--unix-socket", "/path/to/dir/", "--unix-socket-lock", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				UnixSocket:     "/path/to/dir/",
+				UnixSocketLock: true,
+			}),
+		},
+		{
+			desc: "using the unix-socket-port query param",
+			args: []string{
+				"--unix-socket", "/path/to/dir/",
+				"proj:region:inst?unix-socket-port=5433",
+				"proj:region:inst2?unix-socket-port=5434",
+			},
+			want: withDefaults(&proxy.Config{
+				UnixSocket: "/path/to/dir/",
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "proj:region:inst", UnixSocketPort: 5433},
+					{Name: "proj:region:inst2", UnixSocketPort: 5434},
+				},
+			}),
+		},
+		{
+			desc: "using the Unix socket name template flag",
+			args: []string{
+				"--unix-socket", "/path/to/dir/",
+				"--unix-socket-name-template", "{project}-{instance}",
+				"proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				UnixSocket:             "/path/to/dir/",
+				UnixSocketNameTemplate: "{project}-{instance}",
+			}),
+		},
 		{
 			desc: "using the quota project flag",
 			args: []string{"--quota-project", "proj", "proj:region:inst"},
@@ -499,6 +672,85 @@ func TestNewCommandArguments(t *testing.T) {
 				}},
 			}),
 		},
+		{
+			desc: "using the connection-queue-timeout flag",
+			args: []string{"--max-connections", "5", "--connection-queue-timeout", "10s", "proj:region:inst"},
+			want: withDefaults(&proxy.Config{
+				MaxConnections:   5,
+				ConnQueueTimeout: 10 * time.Second,
+			}),
+		},
+		{
+			desc: "using the max-connections and connection-queue-timeout query params",
+			args: []string{"proj:region:inst?max-connections=3&connection-queue-timeout=2s"},
+			want: withDefaults(&proxy.Config{
+				Instances: []proxy.InstanceConnConfig{{
+					MaxConnections:   3,
+					ConnQueueTimeout: pointer(2 * time.Second),
+				}},
+			}),
+		},
+		{
+			desc: "using the dial retry flags",
+			args: []string{
+				"--dial-timeout", "5s", "--dial-retries", "3",
+				"--dial-retry-backoff", "100ms", "proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				DialTimeout:      5 * time.Second,
+				DialRetries:      3,
+				DialRetryBackoff: 100 * time.Millisecond,
+			}),
+		},
+		{
+			desc: "using the dial retry query params",
+			args: []string{"proj:region:inst?dial-timeout=5s&dial-retries=3&dial-retry-backoff=1s"},
+			want: withDefaults(&proxy.Config{
+				Instances: []proxy.InstanceConnConfig{{
+					DialTimeout:      pointer(5 * time.Second),
+					DialRetries:      pointer(3),
+					DialRetryBackoff: pointer(time.Second),
+				}},
+			}),
+		},
+		{
+			desc: "using the idle timeout and max lifetime flags",
+			args: []string{
+				"--idle-timeout", "30m", "--max-connection-lifetime", "1h",
+				"proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				IdleTimeout:     30 * time.Minute,
+				MaxConnLifetime: time.Hour,
+			}),
+		},
+		{
+			desc: "using the idle timeout and max lifetime query params",
+			args: []string{"proj:region:inst?idle-timeout=30m&max-connection-lifetime=1h"},
+			want: withDefaults(&proxy.Config{
+				Instances: []proxy.InstanceConnConfig{{
+					IdleTimeout:     pointer(30 * time.Minute),
+					MaxConnLifetime: pointer(time.Hour),
+				}},
+			}),
+		},
+		{
+			desc: "using the OTLP flags",
+			args: []string{
+				"--otlp-endpoint", "http://localhost:4318",
+				"--otlp-protocol", "http",
+				"--otlp-headers", "authorization=secret,tenant=db",
+				"proj:region:inst",
+			},
+			want: withDefaults(&proxy.Config{
+				OTLPEndpoint: "http://localhost:4318",
+				OTLPProtocol: "http",
+				OTLPHeaders: map[string]string{
+					"authorization": "secret",
+					"tenant":        "db",
+				},
+			}),
+		},
 		{
 			desc: "using the resource-exhausted-cooldown-delay flag",
 			args: []string{"--resource-exhausted-cooldown-delay", "10s", "proj:region:inst"},
@@ -1284,6 +1536,18 @@ func TestNewCommandWithErrors(t *testing.T) {
 			desc: "using fuse-tmp-dir without fuse",
 			args: []string{"--fuse-tmp-dir", "/mydir"},
 		},
+		{
+			desc: "using fuse-allow without fuse",
+			args: []string{"--fuse-allow", "proj:*:*", "proj:region:inst"},
+		},
+		{
+			desc: "using fuse-idle-timeout without fuse",
+			args: []string{"--fuse-idle-timeout", "1h", "proj:region:inst"},
+		},
+		{
+			desc: "using fuse-instance-settings without fuse",
+			args: []string{"--fuse-instance-settings", "proj:*:*?private-ip=true", "proj:region:inst"},
+		},
 		{
 			desc: "using --auto-iam-authn with just token flag",
 			args: []string{"--auto-iam-authn", "--token", "MYTOKEN", "p:r:i"},
@@ -1323,6 +1587,66 @@ func TestNewCommandWithErrors(t *testing.T) {
 			desc: "using private IP and psc query params",
 			args: []string{"p:r:i?private-ip=true&psc=true"},
 		},
+		{
+			desc: "using an unknown engine query param",
+			args: []string{"p:r:i?engine=oracle"},
+		},
+		{
+			desc: "using the engine query param twice",
+			args: []string{"p:r:i?engine=mysql&engine=postgres"},
+		},
+		{
+			desc: "using the same unix-socket-port twice in one directory",
+			args: []string{
+				"--unix-socket", "/path/to/dir/",
+				"p:r:i?unix-socket-port=5433",
+				"p:r:j?unix-socket-port=5433",
+			},
+		},
+		{
+			desc: "using unix-socket-port without a Unix socket directory",
+			args: []string{"p:r:i?unix-socket-port=5433"},
+		},
+		{
+			desc: "using unix-socket-port with unix-socket-path",
+			args: []string{"p:r:i?unix-socket-path=/path/to/socket&unix-socket-port=5433"},
+		},
+		{
+			desc: "using an invalid unix-socket-port",
+			args: []string{"--unix-socket", "/path/to/dir/", "p:r:i?unix-socket-port=70000"},
+		},
+		{
+			desc: "using --unix-socket-lock without --unix-socket",
+			args: []string{"--unix-socket-lock", "p:r:i"},
+		},
+		{
+			desc: "using an invalid Unix socket mode",
+			args: []string{"--unix-socket-mode", "rwx", "p:r:i"},
+		},
+		{
+			desc: "using a Unix socket mode that is too large",
+			args: []string{"--unix-socket-mode", "7777", "p:r:i"},
+		},
+		{
+			desc: "using a negative Unix socket uid",
+			args: []string{"--unix-socket-uid", "-2", "p:r:i"},
+		},
+		{
+			desc: "using --unix-socket-gid with --unix-socket-group",
+			args: []string{"--unix-socket-gid", "0", "--unix-socket-group", "root", "p:r:i"},
+		},
+		{
+			desc: "using an unknown Unix socket group",
+			args: []string{"--unix-socket-group", "no-such-group-for-the-proxy", "p:r:i"},
+		},
+		{
+			desc: "using an unknown placeholder in the Unix socket name template",
+			args: []string{"--unix-socket-name-template", "{zone}-{instance}", "p:r:i"},
+		},
+		{
+			desc: "using a path in the Unix socket name template",
+			args: []string{"--unix-socket-name-template", "{project}/{instance}", "p:r:i"},
+		},
 		{
 			desc: "using --private-ip with --psc",
 			args: []string{
@@ -1337,6 +1661,56 @@ func TestNewCommandWithErrors(t *testing.T) {
 				"--fuse", "myfusedir",
 			},
 		},
+		{
+			desc: "upgrade-on-sigusr2 with fuse",
+			args: []string{
+				"--upgrade-on-sigusr2",
+				"--fuse", "myfusedir",
+			},
+		},
+		{
+			desc: "when the max-connections query param is not a number",
+			args: []string{"proj:region:inst?max-connections=many"},
+		},
+		{
+			desc: "when the connection-queue-timeout query param is not a duration",
+			args: []string{"proj:region:inst?connection-queue-timeout=soon"},
+		},
+		{
+			desc: "when the dial-retries query param is negative",
+			args: []string{"proj:region:inst?dial-retries=-1"},
+		},
+		{
+			desc: "when the dial-timeout query param is not a duration",
+			args: []string{"proj:region:inst?dial-timeout=30"},
+		},
+		{
+			desc: "when the OTLP endpoint is not a URL",
+			args: []string{"--otlp-endpoint", "localhost:4317", "proj:region:inst"},
+		},
+		{
+			desc: "when the OTLP protocol is unknown",
+			args: []string{
+				"--otlp-endpoint", "http://localhost:4317",
+				"--otlp-protocol", "thrift", "proj:region:inst",
+			},
+		},
+		{
+			desc: "when the readiness failure threshold is zero",
+			args: []string{"--readiness-failure-threshold", "0", "proj:region:inst"},
+		},
+		{
+			desc: "when the log level is unknown",
+			args: []string{"--log-level", "verbose", "proj:region:inst"},
+		},
+		{
+			desc: "when the log format is unknown",
+			args: []string{"--log-format", "xml", "proj:region:inst"},
+		},
+		{
+			desc: "when the idle-timeout query param is not a duration",
+			args: []string{"proj:region:inst?idle-timeout=forever"},
+		},
 		{
 			desc: "using both --sqladmin-api-endpoint and --universe-domain",
 			args: []string{
@@ -1453,6 +1827,53 @@ func TestPrometheusMetricsEndpoint(t *testing.T) {
 	}
 }
 
+func TestOTLPExport(t *testing.T) {
+	// The collector stand-in records the path and headers of every export.
+	var (
+		mu      sync.Mutex
+		paths   []string
+		tenants []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		tenants = append(tenants, r.Header.Get("tenant"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{
+		"--otlp-endpoint", srv.URL, "--otlp-protocol", "http",
+		"--otlp-headers", "tenant=db", "--disable-traces",
+		"my-project:my-region:my-instance",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Metrics are flushed to the collector on shutdown.
+	if err := c.ExecuteContext(ctx); !errors.Is(err, errSigInt) {
+		t.Fatalf("want errSigInt, got = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) == 0 {
+		t.Fatal("want the collector to receive an export, got none")
+	}
+	for i, p := range paths {
+		if p != "/v1/metrics" {
+			t.Fatalf("want export to /v1/metrics, got = %v", p)
+		}
+		if tenants[i] != "db" {
+			t.Fatalf("want tenant header = db, got = %q", tenants[i])
+		}
+	}
+}
+
 func TestPProfServer(t *testing.T) {
 	c := NewCommand(WithDialer(&spyDialer{}))
 	c.SilenceUsage = true
@@ -1547,6 +1968,227 @@ func TestQuitQuitQuitHTTPGet(t *testing.T) {
 	}
 }
 
+func TestInstancesAPI(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{
+		"--instances-api", "--admin-port", "9195",
+		"my-project:my-region:my-instance",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	resp, err := tryDial("GET", "http://localhost:9195/instances")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+
+	inst := url.QueryEscape("my-project:my-region:other-instance?port=24021")
+	resp, err = tryDial("POST", "http://localhost:9195/instances?instance="+inst)
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+	conn, err := net.Dial("tcp", "127.0.0.1:24021")
+	if err != nil {
+		t.Fatalf("want added instance to be listening, got = %v", err)
+	}
+	conn.Close()
+
+	resp, err = tryDial("POST", "http://localhost:9195/instances?instance="+inst)
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected a 409 status, got = %v", resp.StatusCode)
+	}
+
+	// The port is taken, so the mount fails on the server side.
+	taken := url.QueryEscape("my-project:my-region:third-instance?port=24021")
+	resp, err = tryDial("POST", "http://localhost:9195/instances?instance="+taken)
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 status, got = %v", resp.StatusCode)
+	}
+
+	resp, err = tryDial("DELETE", "http://localhost:9195/instances?instance=my-project:my-region:other-instance")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+
+	resp, err = tryDial("DELETE", "http://localhost:9195/instances?instance=my-project:my-region:other-instance")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 status, got = %v", resp.StatusCode)
+	}
+}
+
+func TestConnectionsAPI(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{
+		"--connections-api", "--admin-port", "9196",
+		"my-project:my-region:my-instance",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.ExecuteContext(ctx)
+
+	resp, err := tryDial("GET", "http://localhost:9196/connections")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+	var got []proxy.ConnInfo
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("want no connections, got = %v", got)
+	}
+
+	resp, err = tryDial("POST", "http://localhost:9196/connections/close?id=1")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 status, got = %v", resp.StatusCode)
+	}
+
+	resp, err = tryDial("POST", "http://localhost:9196/connections/close?id=abc")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a 400 status, got = %v", resp.StatusCode)
+	}
+}
+
+func TestDrainAPI(t *testing.T) {
+	c := NewCommand(WithDialer(&spyDialer{}))
+	c.SilenceUsage = true
+	c.SilenceErrors = true
+	c.SetArgs([]string{
+		"--drain-api", "--admin-port", "9197",
+		"--health-check", "--http-port", "9198",
+		"my-project:my-region:my-instance",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- c.ExecuteContext(ctx)
+	}()
+
+	// Wait for the Proxy to become ready.
+	var resp *http.Response
+	for i := 0; i < 10; i++ {
+		var err error
+		resp, err = tryDial("GET", "http://localhost:9198/readiness")
+		if err != nil {
+			t.Fatalf("failed to dial endpoint: %v", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+
+	resp, err := tryDial("POST", "http://localhost:9197/drain?stop-accepting=bogus")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 status, got = %v", resp.StatusCode)
+	}
+
+	resp, err = tryDial("POST", "http://localhost:9197/drain")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+	resp, err = tryDial("GET", "http://localhost:9198/readiness")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 status, got = %v", resp.StatusCode)
+	}
+
+	// With no open connections, the Proxy shuts down right away.
+	resp, err = tryDial("POST", "http://localhost:9197/drain?stop-accepting=all&when-idle=true")
+	if err != nil {
+		t.Fatalf("failed to dial endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a 200 status, got = %v", resp.StatusCode)
+	}
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, errDrained) {
+			t.Fatalf("want = %v, got = %v", errDrained, err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+}
+
+func TestDrainerShutdownWaitsForReceiver(t *testing.T) {
+	cmd, err := invokeProxyCommand([]string{"proj:region:inst?port=24038"})
+	if err != nil {
+		t.Fatalf("want error = nil, got = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := log.NewStdLogger(io.Discard, io.Discard)
+	p, err := proxy.NewClient(ctx, &spyDialer{}, l, cmd.conf, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer p.Close()
+
+	shutdownCh := make(chan error)
+	d := &drainer{ctx: ctx, p: p, logger: l, shutdownCh: shutdownCh}
+	go d.shutdownAfter(true, 0)
+
+	// Nothing receives the shutdown for a while, as when the Proxy is busy
+	// starting up. The shutdown must still be delivered.
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case err := <-shutdownCh:
+		if !errors.Is(err, errDrained) {
+			t.Fatalf("want = %v, got = %v", errDrained, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the drain to shut down the Proxy")
+	}
+}
+
 type errorDialer struct {
 	spyDialer
 }
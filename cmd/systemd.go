@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+	"github.com/coreos/go-systemd/v22/activation"
+)
+
+// systemdStatusInterval is how often the status is reported to systemd when
+// the watchdog is disabled.
+const systemdStatusInterval = 10 * time.Second
+
+// activatedListeners returns the listeners passed by systemd socket
+// activation, keyed by the instance connection name they serve. It returns
+// nil when the Proxy was not started by socket activation.
+func activatedListeners(conf *proxy.Config) (map[string]net.Listener, error) {
+	named, err := activation.ListenersWithNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(named) == 0 {
+		return nil, nil
+	}
+	lns, err := matchListeners(conf.Instances, named)
+	if err != nil {
+		for _, ls := range named {
+			for _, ln := range ls {
+				_ = ln.Close()
+			}
+		}
+		return nil, err
+	}
+	return lns, nil
+}
+
+// matchListeners matches named listeners to instances. A listener is named
+// for an instance with the instance connection name, with colons replaced by
+// periods because systemd does not allow colons in names, e.g.,
+// my-project.us-central1.my-db-server. With one instance and one listener,
+// the listener is used whatever its name.
+func matchListeners(insts []proxy.InstanceConnConfig, named map[string][]net.Listener) (map[string]net.Listener, error) {
+	if len(insts) == 1 && len(named) == 1 {
+		for name, ls := range named {
+			if len(ls) != 1 {
+				return nil, fmt.Errorf("want one activated listener named %q, got %d", name, len(ls))
+			}
+			return map[string]net.Listener{insts[0].Name: ls[0]}, nil
+		}
+	}
+	lns := make(map[string]net.Listener)
+	for _, inst := range insts {
+		name := strings.ReplaceAll(inst.Name, ":", ".")
+		ls, ok := named[name]
+		if !ok {
+			continue
+		}
+		if len(ls) != 1 {
+			return nil, fmt.Errorf("want one activated listener named %q, got %d", name, len(ls))
+		}
+		lns[inst.Name] = ls[0]
+	}
+	for name := range named {
+		if !matchesInstance(insts, name) {
+			return nil, fmt.Errorf("activated listener %q does not match any instance", name)
+		}
+	}
+	return lns, nil
+}
+
+// matchesInstance reports whether the listener name is for one of the
+// instances.
+func matchesInstance(insts []proxy.InstanceConnConfig, name string) bool {
+	for _, inst := range insts {
+		if strings.ReplaceAll(inst.Name, ":", ".") == name {
+			return true
+		}
+	}
+	return false
+}
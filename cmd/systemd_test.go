@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+)
+
+func TestMatchListeners(t *testing.T) {
+	ln1, ln2 := &net.TCPListener{}, &net.TCPListener{}
+	insts := func(names ...string) []proxy.InstanceConnConfig {
+		var ics []proxy.InstanceConnConfig
+		for _, n := range names {
+			ics = append(ics, proxy.InstanceConnConfig{Name: n})
+		}
+		return ics
+	}
+	tcs := []struct {
+		desc    string
+		insts   []proxy.InstanceConnConfig
+		named   map[string][]net.Listener
+		want    map[string]net.Listener
+		wantErr bool
+	}{
+		{
+			desc:  "listeners named for instances",
+			insts: insts("p:r:a", "p:r:b", "p:r:c"),
+			named: map[string][]net.Listener{
+				"p.r.a": {ln1},
+				"p.r.b": {ln2},
+			},
+			want: map[string]net.Listener{"p:r:a": ln1, "p:r:b": ln2},
+		},
+		{
+			desc:  "one instance and one listener with any name",
+			insts: insts("p:r:a"),
+			named: map[string][]net.Listener{"cloud-sql-proxy.socket": {ln1}},
+			want:  map[string]net.Listener{"p:r:a": ln1},
+		},
+		{
+			desc:  "unknown listener name",
+			insts: insts("p:r:a", "p:r:b"),
+			named: map[string][]net.Listener{
+				"p.r.a":   {ln1},
+				"unknown": {ln2},
+			},
+			wantErr: true,
+		},
+		{
+			desc:    "two listeners with one name",
+			insts:   insts("p:r:a", "p:r:b"),
+			named:   map[string][]net.Listener{"p.r.a": {ln1, ln2}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := matchListeners(tc.insts, tc.named)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got = %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matchListeners error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+			for name, ln := range tc.want {
+				if got[name] != ln {
+					t.Fatalf("listener for %v: want = %p, got = %p", name, ln, got[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/healthcheck"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+const (
+	// upgradeListenersEnv lists the instance connection names of the
+	// listeners handed to a new process during an upgrade, in the order of
+	// their file descriptors.
+	upgradeListenersEnv = "CLOUD_SQL_PROXY_UPGRADE_LISTENERS"
+	// upgradeActivatedEnv lists the instance connection names of the handed
+	// over listeners that were passed by socket activation. The Proxy did
+	// not create their sockets, so it never removes or recreates them.
+	upgradeActivatedEnv = "CLOUD_SQL_PROXY_UPGRADE_ACTIVATED"
+	// upgradeFirstFD is the file descriptor of the first handed over
+	// listener. The file used to report that the new process has started
+	// follows the last listener.
+	upgradeFirstFD = 3
+	// upgradeTimeout is how long to wait for the new process to start before
+	// abandoning the upgrade.
+	upgradeTimeout = time.Minute
+)
+
+// upgrader replaces the running Proxy with a new copy of the binary without
+// refusing connections. The listeners are handed to the new process, which
+// accepts on them as soon as it has started. The running Proxy then stops
+// accepting and shuts down once its open connections finish.
+type upgrader struct {
+	// ctx is done once the Proxy is exiting.
+	ctx        context.Context
+	p          *proxy.Client
+	hc         *healthcheck.Check
+	logger     cloudsql.Logger
+	shutdownCh chan<- error
+	// stopHTTP stops the HTTP servers so the new process can bind their
+	// ports.
+	stopHTTP func()
+	// stopSystemd stops the systemd notifications, so that only the new
+	// process reports to systemd once it is the main process.
+	stopSystemd func()
+
+	// mu ensures only one upgrade runs at a time.
+	mu       sync.Mutex
+	upgraded bool
+}
+
+// watch upgrades the Proxy when it receives an upgrade signal. It returns
+// when ctx is done.
+func (u *upgrader) watch(ctx context.Context) {
+	if len(upgradeSignals) == 0 {
+		return
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, upgradeSignals...)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case s := <-sig:
+			u.logger.Infof("%v signal received. Upgrading...", s)
+		case <-ctx.Done():
+			return
+		}
+		if err := u.upgrade(); err != nil {
+			u.logger.Errorf("Failed to upgrade: %v", err)
+		}
+	}
+}
+
+// handle upgrades the Proxy on a POST request.
+func (u *upgrader) handle(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	u.logger.Infof("/upgrade received request. Upgrading...")
+	if err := u.upgrade(); err != nil {
+		u.logger.Errorf("Failed to upgrade: %v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// upgrade starts a new process with the listeners and, once it has started,
+// stops accepting new connections and shuts down. If the new process fails to
+// start, the Proxy keeps running as before.
+func (u *upgrader) upgrade() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.upgraded {
+		return errors.New("the Proxy has already been upgraded")
+	}
+	lfs, err := u.p.ListenerFiles()
+	if err != nil {
+		return err
+	}
+	var (
+		names, activated []string
+		files            []*os.File
+	)
+	for _, lf := range lfs {
+		defer lf.File.Close()
+		names = append(names, lf.Name)
+		files = append(files, lf.File)
+		if lf.Activated {
+			activated = append(activated, lf.Name)
+		}
+	}
+	env := []string{
+		upgradeListenersEnv + "=" + strings.Join(names, ","),
+		upgradeActivatedEnv + "=" + strings.Join(activated, ","),
+	}
+	// The new process takes the lock on the Unix socket directory.
+	if err := u.p.UnlockUnixSocketDir(); err != nil {
+		return err
+	}
+	pid, err := startUpgrade(env, files, upgradeTimeout)
+	if err != nil {
+		if lErr := u.p.LockUnixSocketDir(); lErr != nil {
+			u.logger.Errorf("Failed to lock the Unix socket directory again: %v", lErr)
+		}
+		return err
+	}
+	u.upgraded = true
+	u.logger.Infof("New process (pid %d) is accepting connections", pid)
+
+	if u.hc != nil {
+		u.hc.NotifyDraining()
+	}
+	u.p.StopAccepting()
+	// If running under systemd, track the new process as the main process of
+	// the service.
+	u.stopSystemd()
+	if _, err := daemon.SdNotify(false, fmt.Sprintf("MAINPID=%d", pid)); err != nil {
+		u.logger.Errorf("Failed to notify systemd of the new main process: %v", err)
+	}
+	u.stopHTTP()
+	// The Proxy no longer accepts connections, so it must exit.
+	select {
+	case u.shutdownCh <- errUpgraded:
+	case <-u.ctx.Done():
+		// The proxy is already exiting.
+	}
+	return nil
+}
+
+// inheritedListeners returns the listeners handed over by the process being
+// upgraded, keyed by the instance connection name they serve, along with the
+// file used to report that the new process has started. The listeners the
+// upgraded process created are returned in owned, and those it was passed by
+// socket activation in activated. It returns nil maps when the Proxy was not
+// started by an upgrade.
+func inheritedListeners() (owned, activated map[string]net.Listener, ready *os.File, err error) {
+	v, ok := os.LookupEnv(upgradeListenersEnv)
+	if !ok {
+		return nil, nil, nil, nil
+	}
+	act := os.Getenv(upgradeActivatedEnv)
+	// Keep the variables from reaching a later upgrade.
+	os.Unsetenv(upgradeListenersEnv)
+	os.Unsetenv(upgradeActivatedEnv)
+	isActivated := make(map[string]bool)
+	if act != "" {
+		for _, n := range strings.Split(act, ",") {
+			isActivated[n] = true
+		}
+	}
+	var names []string
+	if v != "" {
+		names = strings.Split(v, ",")
+	}
+	owned = make(map[string]net.Listener)
+	activated = make(map[string]net.Listener)
+	for i, n := range names {
+		f := os.NewFile(uintptr(upgradeFirstFD+i), n)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, lns := range []map[string]net.Listener{owned, activated} {
+				for _, l := range lns {
+					l.Close()
+				}
+			}
+			return nil, nil, nil, fmt.Errorf("[%v] unable to use listener: %v", n, err)
+		}
+		if isActivated[n] {
+			activated[n] = l
+			continue
+		}
+		// Remove the socket when this process stops listening, as the
+		// upgraded process would have.
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(true)
+		}
+		owned[n] = l
+	}
+	ready = os.NewFile(uintptr(upgradeFirstFD+len(names)), "upgrade-ready")
+	return owned, activated, ready, nil
+}
+
+// listenTCP listens on addr. When retry is set, the address may still be in
+// use by the process being upgraded, so listening is retried until ctx is
+// done or the upgrade timeout passes.
+func listenTCP(ctx context.Context, addr string, retry bool) (net.Listener, error) {
+	deadline := time.Now().Add(upgradeTimeout)
+	for {
+		ln, err := net.Listen("tcp", addr)
+		if err == nil || !retry || time.Now().After(deadline) {
+			return ln, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
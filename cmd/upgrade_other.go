@@ -0,0 +1,81 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// upgradeSignals are the signals that upgrade the Proxy.
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// startUpgrade starts a new copy of the running binary with the same
+// arguments and hands it the listener files, describing them with the
+// additional environment variables env. It returns the PID of the new
+// process once it reports that it has started. If the new process exits or
+// does not start in time, it is killed and an error is returned.
+func startUpgrade(env []string, files []*os.File, timeout time.Duration) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("unable to find the executable: %v", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	c := exec.Command(exe, os.Args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = append(os.Environ(), env...)
+	c.ExtraFiles = append(append([]*os.File{}, files...), w)
+	err = c.Start()
+	// Only the new process holds the write end, so a read returns EOF if
+	// it exits before reporting that it has started.
+	w.Close()
+	if err != nil {
+		return 0, fmt.Errorf("unable to start new process: %v", err)
+	}
+
+	readyCh := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		readyCh <- err
+	}()
+	select {
+	case err = <-readyCh:
+		if errors.Is(err, io.EOF) {
+			err = errors.New("exited before starting")
+		}
+	case <-time.After(timeout):
+		err = fmt.Errorf("did not start after %v", timeout)
+	}
+	if err != nil {
+		_ = c.Process.Kill()
+		_ = c.Wait()
+		return 0, fmt.Errorf("new process failed: %v", err)
+	}
+	pid := c.Process.Pid
+	_ = c.Process.Release()
+	return pid, nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// upgradeSignals are the signals that upgrade the Proxy. Windows has none.
+var upgradeSignals []os.Signal
+
+// startUpgrade is not supported on Windows.
+func startUpgrade([]string, []*os.File, time.Duration) (int, error) {
+	return 0, errors.New("upgrades are not supported on Windows")
+}
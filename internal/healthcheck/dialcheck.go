@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DialCheckConfig configures the background dial checks that make readiness
+// depend on whether the instances are reachable.
+type DialCheckConfig struct {
+	// Interval is the time between checks.
+	Interval time.Duration
+	// Timeout limits how long a check may take. Zero means no limit other
+	// than the interval.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failed dials before an
+	// instance is reported as unreachable.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful dials before
+	// an unreachable instance is reported as reachable again.
+	SuccessThreshold int
+}
+
+// InstanceHealth reports the results of the dial checks for an instance.
+type InstanceHealth struct {
+	// Name is the instance connection name.
+	Name string `json:"name"`
+	// State is set for instances that are not mounted yet, e.g., "pending"
+	// while the mount is retried. Such instances are not dialed.
+	State string `json:"state,omitempty"`
+	// Reachable is false once the instance has failed FailureThreshold
+	// consecutive checks, and true again after SuccessThreshold consecutive
+	// successful checks.
+	Reachable bool `json:"reachable"`
+	// LastSuccess is the time of the last successful dial.
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// LastError is the error of the last failed dial.
+	LastError string `json:"lastError,omitempty"`
+	// LastErrorTime is the time of the last failed dial.
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+	// ConsecutiveFailures is the number of failed dials since the last
+	// successful dial.
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+}
+
+// dialChecks caches the results of the dial checks by instance.
+type dialChecks struct {
+	conf DialCheckConfig
+
+	mu    sync.Mutex
+	insts map[string]*instanceState
+}
+
+// instanceState tracks the dial check results for an instance.
+type instanceState struct {
+	health    InstanceHealth
+	successes int
+}
+
+// StartDialChecks dials every instance on the configured interval until ctx
+// is done. Once started, readiness fails when an instance is unreachable.
+func (c *Check) StartDialChecks(ctx context.Context, conf DialCheckConfig) {
+	if conf.FailureThreshold < 1 {
+		conf.FailureThreshold = 1
+	}
+	if conf.SuccessThreshold < 1 {
+		conf.SuccessThreshold = 1
+	}
+	d := &dialChecks{conf: conf, insts: make(map[string]*instanceState)}
+	c.mu.Lock()
+	c.dial = d
+	c.mu.Unlock()
+
+	go func() {
+		t := time.NewTicker(conf.Interval)
+		defer t.Stop()
+		for {
+			c.runDialCheck(ctx, d)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// runDialCheck dials every instance once and records the results.
+func (c *Check) runDialCheck(ctx context.Context, d *dialChecks) {
+	timeout := d.conf.Timeout
+	if timeout <= 0 {
+		timeout = d.conf.Interval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res := c.proxy.CheckInstanceConnections(ctx)
+	if ctx.Err() != nil && ctx.Err() != context.DeadlineExceeded {
+		// The checks were stopped, so the results are meaningless.
+		return
+	}
+	d.record(c, res, time.Now())
+}
+
+// record updates the cached state of every instance with the results of a
+// check. Instances that are no longer mounted are dropped.
+func (d *dialChecks) record(c *Check, res map[string]error, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for inst := range d.insts {
+		if _, ok := res[inst]; !ok {
+			delete(d.insts, inst)
+		}
+	}
+	for inst, err := range res {
+		s, ok := d.insts[inst]
+		if !ok {
+			s = &instanceState{health: InstanceHealth{Name: inst, Reachable: true}}
+			d.insts[inst] = s
+		}
+		if err != nil {
+			t := now
+			s.health.LastError = err.Error()
+			s.health.LastErrorTime = &t
+			s.health.ConsecutiveFailures++
+			s.successes = 0
+			if s.health.Reachable && s.health.ConsecutiveFailures >= d.conf.FailureThreshold {
+				s.health.Reachable = false
+				c.logger.Errorf("[Health Check] Instance %v is unreachable: %v", inst, err)
+			}
+			continue
+		}
+		t := now
+		s.health.LastSuccess = &t
+		s.health.ConsecutiveFailures = 0
+		s.successes++
+		if !s.health.Reachable && s.successes >= d.conf.SuccessThreshold {
+			s.health.Reachable = true
+			c.logger.Infof("[Health Check] Instance %v is reachable again", inst)
+		}
+	}
+}
+
+// health returns the cached results for all instances, sorted by name, or
+// for the named instance only.
+func (d *dialChecks) health(inst string) []InstanceHealth {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var hs []InstanceHealth
+	for name, s := range d.insts {
+		if inst == "" || inst == name {
+			hs = append(hs, s.health)
+		}
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Name < hs[j].Name })
+	return hs
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Service names reported by the gRPC health server in addition to one service
+// per instance connection name. The empty service name reports readiness.
+const (
+	ServiceStartup   = "startup"
+	ServiceReadiness = "readiness"
+	ServiceLiveness  = "liveness"
+)
+
+// GRPCHealth reports the startup, readiness, and liveness checks using the
+// gRPC health checking protocol. Each instance connection name is also a
+// service that reports the readiness of that instance.
+type GRPCHealth struct {
+	check *Check
+	srv   *health.Server
+
+	// mu serializes updates and protects insts.
+	mu sync.Mutex
+	// insts is the set of instances with a reported status.
+	insts map[string]bool
+}
+
+// NewGRPCHealth creates a GRPCHealth that reports the status of the Check.
+// Status changes from NotifyStarted and NotifyStopped are reported right
+// away. All other changes are reported by Run.
+func NewGRPCHealth(c *Check) *GRPCHealth {
+	g := &GRPCHealth{
+		check: c,
+		srv:   health.NewServer(),
+		insts: make(map[string]bool),
+	}
+	g.update()
+	c.subscribe(g.update)
+	return g
+}
+
+// Register registers the health service with the gRPC server.
+func (g *GRPCHealth) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, g.srv)
+}
+
+// Run refreshes the reported status on the interval until ctx is done.
+func (g *GRPCHealth) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			g.update()
+		}
+	}
+}
+
+// update reports the current status of every service.
+func (g *GRPCHealth) update() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.check.stopped:
+		// Shutdown reports every service as not serving and ignores any
+		// later updates.
+		g.srv.Shutdown()
+		return
+	default:
+	}
+
+	started := true
+	select {
+	case <-g.check.started:
+	default:
+		started = false
+	}
+	g.srv.SetServingStatus(ServiceStartup, servingStatus(started))
+	ready := g.check.readiness("") == nil
+	g.srv.SetServingStatus("", servingStatus(ready))
+	g.srv.SetServingStatus(ServiceReadiness, servingStatus(ready))
+	g.srv.SetServingStatus(ServiceLiveness, servingStatus(g.check.liveness(time.Now()) == nil))
+
+	current := make(map[string]bool)
+	for _, i := range g.check.proxy.Instances() {
+		current[i.Name] = true
+		g.srv.SetServingStatus(i.Name, servingStatus(g.check.readiness(i.Name) == nil))
+	}
+	for inst := range g.insts {
+		if !current[inst] {
+			g.srv.SetServingStatus(inst, healthpb.HealthCheckResponse_SERVICE_UNKNOWN)
+		}
+	}
+	g.insts = current
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
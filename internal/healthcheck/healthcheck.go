@@ -16,10 +16,14 @@
 package healthcheck
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
@@ -32,8 +36,21 @@ type Check struct {
 	started     chan struct{}
 	stoppedOnce *sync.Once
 	stopped     chan struct{}
+	drainOnce   *sync.Once
+	draining    chan struct{}
 	proxy       *proxy.Client
 	logger      cloudsql.Logger
+
+	// mu protects dial, live, and subscribers.
+	mu sync.Mutex
+	// dial holds the results of the background dial checks, and is nil
+	// unless the checks have been started.
+	dial *dialChecks
+	// live holds the conditions that fail the liveness check.
+	live LivenessConfig
+	// subscribers are called after startup, draining, or shutdown is
+	// notified.
+	subscribers []func()
 }
 
 // NewCheck is the initializer for Check.
@@ -43,6 +60,8 @@ func NewCheck(p *proxy.Client, l cloudsql.Logger) *Check {
 		started:     make(chan struct{}),
 		stoppedOnce: &sync.Once{},
 		stopped:     make(chan struct{}),
+		drainOnce:   &sync.Once{},
+		draining:    make(chan struct{}),
 		proxy:       p,
 		logger:      l,
 	}
@@ -50,12 +69,44 @@ func NewCheck(p *proxy.Client, l cloudsql.Logger) *Check {
 
 // NotifyStarted notifies the check that the proxy has started up successfully.
 func (c *Check) NotifyStarted() {
-	c.startedOnce.Do(func() { close(c.started) })
+	c.startedOnce.Do(func() {
+		close(c.started)
+		c.notifySubscribers()
+	})
 }
 
 // NotifyStopped notifies the check that the proxy has started up successfully.
 func (c *Check) NotifyStopped() {
-	c.stoppedOnce.Do(func() { close(c.stopped) })
+	c.stoppedOnce.Do(func() {
+		close(c.stopped)
+		c.notifySubscribers()
+	})
+}
+
+// NotifyDraining notifies the check that the proxy is draining, so that it
+// is no longer ready for new connections.
+func (c *Check) NotifyDraining() {
+	c.drainOnce.Do(func() {
+		close(c.draining)
+		c.notifySubscribers()
+	})
+}
+
+// subscribe registers f to be called after startup, draining, or shutdown is
+// notified.
+func (c *Check) subscribe(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, f)
+}
+
+func (c *Check) notifySubscribers() {
+	c.mu.Lock()
+	subs := c.subscribers
+	c.mu.Unlock()
+	for _, f := range subs {
+		f()
+	}
 }
 
 // HandleStartup reports whether the Check has been notified of startup.
@@ -73,47 +124,222 @@ func (c *Check) HandleStartup(w http.ResponseWriter, _ *http.Request) {
 var (
 	errNotStarted = errors.New("proxy is not started")
 	errStopped    = errors.New("proxy has stopped")
+	errNotServing = errors.New("no socket is accepting connections")
+	errDraining   = errors.New("proxy is draining")
 )
 
 // HandleReadiness ensures the Check has been notified of successful startup,
 // that the proxy has not reached maximum connections, and that the Proxy has
-// not started shutting down.
-func (c *Check) HandleReadiness(w http.ResponseWriter, _ *http.Request) {
-	select {
-	case <-c.started:
-	default:
-		c.logger.Errorf("[Health Check] Readiness failed: %v", errNotStarted)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte(errNotStarted.Error()))
+// not started draining or shutting down. When dial checks have been started,
+// it also ensures the instances are reachable and responds with the results
+// of the checks as JSON.
+//
+// The instance query parameter limits the checks to a single instance, e.g.,
+// /readiness?instance=my-project:us-central1:my-instance
+func (c *Check) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	inst := r.URL.Query().Get("instance")
+	if inst != "" && !c.mounted(inst) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("instance %v not found", inst)))
 		return
 	}
 
-	select {
-	case <-c.stopped:
-		c.logger.Errorf("[Health Check] Readiness failed: %v", errStopped)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte(errStopped.Error()))
+	err := c.readiness(inst)
+	if err != nil {
+		c.logger.Errorf("[Health Check] Readiness failed: %v", err)
+	}
+
+	c.mu.Lock()
+	d := c.dial
+	c.mu.Unlock()
+	if d != nil {
+		resp := readinessResponse{Status: "ok", Instances: c.instanceHealth(d, inst)}
+		code := http.StatusOK
+		if err != nil {
+			resp.Status, resp.Error = "error", err.Error()
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(resp)
 		return
-	default:
 	}
 
-	if open, maxCount := c.proxy.ConnCount(); maxCount > 0 && open == maxCount {
-		err := fmt.Errorf("max connections reached (open = %v, max = %v)", open, maxCount)
-		c.logger.Errorf("[Health Check] Readiness failed: %v", err)
+	if err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		w.Write([]byte(err.Error()))
 		return
 	}
-
 	// No error cases apply, 200 status.
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 }
 
+// readinessResponse is the JSON body of a readiness response when dial checks
+// have been started.
+type readinessResponse struct {
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Instances []InstanceHealth `json:"instances"`
+}
+
+// instanceHealth returns the dial check results, along with the instances
+// that are not mounted yet, sorted by name, or for the named instance only.
+func (c *Check) instanceHealth(d *dialChecks, inst string) []InstanceHealth {
+	hs := d.health(inst)
+	for _, i := range c.proxy.Instances() {
+		if i.State == proxy.InstanceMounted || (inst != "" && inst != i.Name) {
+			continue
+		}
+		hs = append(hs, InstanceHealth{Name: i.Name, State: i.State, LastError: i.Error})
+	}
+	sort.Slice(hs, func(i, j int) bool { return hs[i].Name < hs[j].Name })
+	return hs
+}
+
+// mounted reports whether the proxy knows about the instance, whether or not
+// its socket is mounted yet.
+func (c *Check) mounted(inst string) bool {
+	for _, i := range c.proxy.Instances() {
+		if i.Name == inst {
+			return true
+		}
+	}
+	return false
+}
+
+// readiness returns the reason the proxy, or the named instance if inst is
+// set, is not ready, or nil if it is ready.
+func (c *Check) readiness(inst string) error {
+	select {
+	case <-c.started:
+	default:
+		return errNotStarted
+	}
+
+	select {
+	case <-c.stopped:
+		return errStopped
+	default:
+	}
+
+	select {
+	case <-c.draining:
+		return errDraining
+	default:
+	}
+	// The proxy may have been drained without notifying the check.
+	if c.proxy.Draining() {
+		return errDraining
+	}
+
+	if open, maxCount := c.proxy.ConnCount(); maxCount > 0 && open == maxCount {
+		queued, wait := c.proxy.ConnQueue()
+		return fmt.Errorf(
+			"max connections reached (open = %v, max = %v, queued = %v, last queue wait = %v)",
+			open, maxCount, queued, wait,
+		)
+	}
+	if inst != "" {
+		for _, i := range c.proxy.Instances() {
+			if i.Name == inst && i.State != proxy.InstanceMounted {
+				return fmt.Errorf("instance is %v: %v", i.State, i.Error)
+			}
+			if i.Name == inst && i.MaxConnections > 0 && i.OpenConnections >= i.MaxConnections {
+				return fmt.Errorf(
+					"max connections reached for instance (open = %v, max = %v, queued = %v)",
+					i.OpenConnections, i.MaxConnections, i.QueuedConnections,
+				)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	d := c.dial
+	c.mu.Unlock()
+	if d == nil {
+		return nil
+	}
+	var unreachable []string
+	for _, h := range d.health(inst) {
+		if !h.Reachable {
+			unreachable = append(unreachable, fmt.Sprintf("%v (%v)", h.Name, h.LastError))
+		}
+	}
+	if len(unreachable) > 0 {
+		return fmt.Errorf("instances unreachable: %v", strings.Join(unreachable, ", "))
+	}
+	return nil
+}
+
+// LivenessConfig holds the conditions that fail the liveness check. A zero
+// value disables a condition.
+type LivenessConfig struct {
+	// RequireServing fails liveness when no mounted socket is accepting
+	// connections.
+	RequireServing bool
+	// MaxDialFailure fails liveness once every dial has failed for this
+	// long.
+	MaxDialFailure time.Duration
+	// MaxAcceptFailure fails liveness once an accept loop has failed for
+	// this long.
+	MaxAcceptFailure time.Duration
+}
+
+// SetLivenessConfig sets the conditions that fail the liveness check.
+func (c *Check) SetLivenessConfig(conf LivenessConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.live = conf
+}
+
 // HandleLiveness indicates the process is up and responding to HTTP requests.
 // If this check fails (because it's not reachable), the process is in a bad
-// state and should be restarted.
+// state and should be restarted. Once the proxy has started, liveness also
+// fails when any of the configured liveness conditions apply.
 func (c *Check) HandleLiveness(w http.ResponseWriter, _ *http.Request) {
+	if err := c.liveness(time.Now()); err != nil {
+		c.logger.Errorf("[Health Check] Liveness failed: %v", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error()))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 }
+
+// liveness returns the reason the proxy is wedged, or nil if it is not.
+func (c *Check) liveness(now time.Time) error {
+	select {
+	case <-c.started:
+	default:
+		// Startup is covered by the startup check.
+		return nil
+	}
+	select {
+	case <-c.stopped:
+		// A stopping proxy is expected to stop serving.
+		return nil
+	default:
+	}
+
+	c.mu.Lock()
+	live := c.live
+	c.mu.Unlock()
+
+	h := c.proxy.Health()
+	if live.RequireServing && h.Mounts > 0 && h.Serving == 0 {
+		return errNotServing
+	}
+	// Failures must continue across the whole window, so a single failure
+	// followed by no further attempts does not fail liveness.
+	if d := live.MaxDialFailure; d > 0 && !h.DialFailingSince.IsZero() &&
+		h.DialLastFailed.Sub(h.DialFailingSince) >= d {
+		return fmt.Errorf("every dial has failed since %v", h.DialFailingSince.Format(time.RFC3339))
+	}
+	if d := live.MaxAcceptFailure; d > 0 && !h.AcceptFailingSince.IsZero() &&
+		h.AcceptLastFailed.Sub(h.AcceptFailingSince) >= d {
+		return fmt.Errorf("accepting connections has failed since %v", h.AcceptFailingSince.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ package healthcheck_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -23,7 +25,9 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -32,6 +36,9 @@ import (
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/healthcheck"
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/log"
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 var (
@@ -71,6 +78,19 @@ func (*fakeDialer) Close() error {
 	return nil
 }
 
+// flakyDialer fails every dial while fail is set.
+type flakyDialer struct {
+	fakeDialer
+	fail atomic.Bool
+}
+
+func (d *flakyDialer) Dial(ctx context.Context, inst string, opts ...cloudsqlconn.DialOption) (net.Conn, error) {
+	if d.fail.Load() {
+		return nil, errors.New("dial failed")
+	}
+	return d.fakeDialer.Dial(ctx, inst, opts...)
+}
+
 func newProxyWithParams(t *testing.T, maxConns uint64, dialer cloudsql.Dialer, instances []proxy.InstanceConnConfig) *proxy.Client {
 	c := &proxy.Config{
 		Addr:           proxyHost,
@@ -174,6 +194,26 @@ func TestHandleReadinessWhenStopped(t *testing.T) {
 	}
 }
 
+func TestHandleReadinessWhenDrained(t *testing.T) {
+	p := newTestProxy(t)
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.NotifyStarted()
+
+	// The proxy was drained without notifying the check.
+	p.Drain(false)
+
+	rec := httptest.NewRecorder()
+	check.HandleReadiness(rec, &http.Request{URL: &url.URL{}})
+	if got, want := rec.Result().StatusCode, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+}
+
 func TestHandleReadinessForMaxConns(t *testing.T) {
 	p := newTestProxyWithMaxConns(t, 1)
 	defer func() {
@@ -222,3 +262,276 @@ func TestHandleReadinessForMaxConns(t *testing.T) {
 		t.Fatalf("want max connections error, got = %v", string(body))
 	}
 }
+
+func TestHandleReadinessWithDialChecks(t *testing.T) {
+	d := &flakyDialer{}
+	d.fail.Store(true)
+	p := newProxyWithParams(t, 0, d, []proxy.InstanceConnConfig{{Name: "proj:region:pg"}})
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.NotifyStarted()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	check.StartDialChecks(ctx, healthcheck.DialCheckConfig{
+		Interval:         10 * time.Millisecond,
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+	})
+
+	waitForReadiness := func(t *testing.T, query string, wantCode int) *http.Response {
+		for i := 0; i < 100; i++ {
+			rec := httptest.NewRecorder()
+			check.HandleReadiness(rec, &http.Request{URL: &url.URL{RawQuery: query}})
+			resp := rec.Result()
+			if resp.StatusCode == wantCode {
+				return resp
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("failed to receive status code = %v", wantCode)
+		return nil
+	}
+
+	resp := waitForReadiness(t, "", http.StatusServiceUnavailable)
+	var body struct {
+		Status    string                       `json:"status"`
+		Instances []healthcheck.InstanceHealth `json:"instances"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response body: %v", err)
+	}
+	if body.Status != "error" || len(body.Instances) != 1 {
+		t.Fatalf("want one instance with error status, got = %+v", body)
+	}
+	if got := body.Instances[0]; got.Reachable || got.LastError != "dial failed" {
+		t.Fatalf("want unreachable instance with last error, got = %+v", got)
+	}
+
+	// The instance recovers once a dial succeeds.
+	d.fail.Store(false)
+	waitForReadiness(t, "instance=proj:region:pg", http.StatusOK)
+
+	// Unknown instances are reported as not found.
+	waitForReadiness(t, "instance=proj:region:unknown", http.StatusNotFound)
+}
+
+func TestHandleReadinessWithPendingInstance(t *testing.T) {
+	c := &proxy.Config{
+		Addr: proxyHost,
+		Port: proxyPort,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg"},
+			// The socket cannot be mounted because the directory is missing.
+			{Name: "proj:region:missing", UnixSocketPath: "/does/not/exist/socket"},
+		},
+		SkipFailedInstanceConfig: true,
+	}
+	p, err := proxy.NewClient(context.Background(), &fakeDialer{}, logger, c, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient: %v", err)
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.NotifyStarted()
+
+	tcs := []struct {
+		query    string
+		wantCode int
+	}{
+		{query: "", wantCode: http.StatusOK},
+		{query: "instance=proj:region:pg", wantCode: http.StatusOK},
+		{query: "instance=proj:region:missing", wantCode: http.StatusServiceUnavailable},
+	}
+	for _, tc := range tcs {
+		rec := httptest.NewRecorder()
+		check.HandleReadiness(rec, &http.Request{URL: &url.URL{RawQuery: tc.query}})
+		if got := rec.Result().StatusCode; got != tc.wantCode {
+			t.Errorf("readiness with %q: want = %v, got = %v", tc.query, tc.wantCode, got)
+		}
+	}
+}
+
+func TestHandleLivenessWhenDialsFail(t *testing.T) {
+	d := &flakyDialer{}
+	d.fail.Store(true)
+	p := newProxyWithParams(t, 0, d, []proxy.InstanceConnConfig{{Name: "proj:region:pg"}})
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.SetLivenessConfig(healthcheck.LivenessConfig{
+		MaxDialFailure: time.Millisecond,
+	})
+	check.NotifyStarted()
+
+	if _, err := p.CheckConnections(context.Background()); err == nil {
+		t.Fatal("want dial error, got nil")
+	}
+	time.Sleep(10 * time.Millisecond)
+	// A single failed dial followed by no traffic does not fail liveness.
+	rec := httptest.NewRecorder()
+	check.HandleLiveness(rec, &http.Request{URL: &url.URL{}})
+	if got, want := rec.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("after one failed dial: want = %v, got = %v", want, got)
+	}
+
+	// Dials that keep failing across the window do.
+	if _, err := p.CheckConnections(context.Background()); err == nil {
+		t.Fatal("want dial error, got nil")
+	}
+	rec = httptest.NewRecorder()
+	check.HandleLiveness(rec, &http.Request{URL: &url.URL{}})
+	if got, want := rec.Result().StatusCode, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+
+	// A single successful dial makes the proxy live again.
+	d.fail.Store(false)
+	if _, err := p.CheckConnections(context.Background()); err != nil {
+		t.Fatalf("CheckConnections: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	check.HandleLiveness(rec, &http.Request{URL: &url.URL{}})
+	if got, want := rec.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+}
+
+func TestHandleLivenessWhenNotServing(t *testing.T) {
+	p := newTestProxy(t)
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.SetLivenessConfig(healthcheck.LivenessConfig{RequireServing: true})
+	// The Check has been notified of startup, but the Proxy is not serving.
+	check.NotifyStarted()
+
+	rec := httptest.NewRecorder()
+	check.HandleLiveness(rec, &http.Request{URL: &url.URL{}})
+	if got, want := rec.Result().StatusCode, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+}
+
+func TestGRPCHealth(t *testing.T) {
+	p := newTestProxy(t)
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	g := healthcheck.NewGRPCHealth(check)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	g.Register(srv)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(ln.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	wantStatus := func(t *testing.T, want healthpb.HealthCheckResponse_ServingStatus) {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if got := resp.GetStatus(); got != want {
+			t.Fatalf("want = %v, got = %v", want, got)
+		}
+	}
+
+	// Readiness is reported for the empty service name.
+	wantStatus(t, healthpb.HealthCheckResponse_NOT_SERVING)
+	check.NotifyStarted()
+	wantStatus(t, healthpb.HealthCheckResponse_SERVING)
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "proj:region:pg"})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if got, want := resp.GetStatus(), healthpb.HealthCheckResponse_SERVING; got != want {
+		t.Fatalf("want = %v, got = %v", want, got)
+	}
+
+	check.NotifyStopped()
+	wantStatus(t, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func TestSystemdWatchdog(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("net.ListenUnixgram: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", sock)
+
+	p := newTestProxy(t)
+	defer func() {
+		if err := p.Close(); err != nil {
+			t.Logf("failed to close proxy client: %v", err)
+		}
+	}()
+	check := healthcheck.NewCheck(p, logger)
+	check.SetLivenessConfig(healthcheck.LivenessConfig{RequireServing: true})
+	check.NotifyStarted()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go healthcheck.NewSystemd(check, true).Run(ctx, 50*time.Millisecond)
+
+	read := func() string {
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("failed to read notification: %v", err)
+		}
+		return string(buf[:n])
+	}
+
+	// No socket is accepting connections, so liveness fails and no
+	// keepalive is sent.
+	if got := read(); !strings.HasPrefix(got, "STATUS=Unhealthy") || strings.Contains(got, "WATCHDOG=1") {
+		t.Fatalf("want unhealthy status without keepalive, got = %q", got)
+	}
+
+	go p.Serve(ctx, func() {})
+	want := "STATUS=Serving 1 of 1 instances, 0 open connections\nWATCHDOG=1"
+	for i := 0; i < 20; i++ {
+		if got := read(); got == want {
+			return
+		}
+	}
+	t.Fatalf("want notification = %q", want)
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// Systemd reports the health of the proxy to systemd with STATUS= lines and,
+// when the watchdog is enabled, WATCHDOG=1 keepalives. Keepalives are only
+// sent while the liveness check passes, so systemd restarts a wedged proxy.
+type Systemd struct {
+	check    *Check
+	watchdog bool
+}
+
+// NewSystemd creates a Systemd that reports the status of the Check. If
+// watchdog is true, it also sends watchdog keepalives.
+func NewSystemd(c *Check, watchdog bool) *Systemd {
+	return &Systemd{check: c, watchdog: watchdog}
+}
+
+// Run reports the status on the interval until ctx is done. With the
+// watchdog enabled, the interval should be half the watchdog timeout.
+func (s *Systemd) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	s.update()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.update()
+		}
+	}
+}
+
+// update sends the current status, and a keepalive if the proxy is live.
+func (s *Systemd) update() {
+	if _, err := daemon.SdNotify(false, s.state(time.Now())); err != nil {
+		s.check.logger.Errorf("Failed to notify systemd of status: %v", err)
+	}
+}
+
+// state returns the notification to send to systemd.
+func (s *Systemd) state(now time.Time) string {
+	if err := s.check.liveness(now); err != nil {
+		return fmt.Sprintf("STATUS=Unhealthy: %v", err)
+	}
+	h := s.check.proxy.Health()
+	open, _ := s.check.proxy.ConnCount()
+	lines := []string{fmt.Sprintf(
+		"STATUS=Serving %d of %d instances, %d open connections",
+		h.Serving, h.Mounts, open,
+	)}
+	if s.watchdog {
+		lines = append(lines, daemon.SdNotifyWatchdog)
+	}
+	return strings.Join(lines, "\n")
+}
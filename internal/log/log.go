@@ -15,89 +15,217 @@
 package log //nolint:revive
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	llog "log"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
 )
 
-// StdLogger is the standard logger that distinguishes between info and error
-// logs.
-type StdLogger struct {
-	stdLog *llog.Logger
-	errLog *llog.Logger
+// Supported log formats.
+const (
+	// FormatText writes a timestamp and message, followed by any fields as
+	// key=value pairs.
+	FormatText = "text"
+	// FormatJSON writes JSON in the LogEntry format.
+	FormatJSON = "json"
+	// FormatLogfmt writes every part of the message as key=value pairs.
+	FormatLogfmt = "logfmt"
+)
+
+// ParseLevel converts a level name (debug, info, warn, or error) to a
+// slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: use debug, info, warn, or error", s)
+	}
+	return l, nil
+}
+
+// Logger writes leveled messages with slog. Error messages are written to a
+// separate writer from all other messages.
+type Logger struct {
+	l *slog.Logger
+}
+
+// NewLogger creates a Logger that writes messages at or above level in the
+// provided format. Error messages go to err and all other messages go to out.
+func NewLogger(out, err io.Writer, format string, level slog.Level) (*Logger, error) {
+	var outHandler, errHandler slog.Handler
+	switch format {
+	case FormatText:
+		outHandler, errHandler = newTextHandler(out), newTextHandler(err)
+	case FormatJSON:
+		opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceAttr}
+		outHandler, errHandler = slog.NewJSONHandler(out, opts), slog.NewJSONHandler(err, opts)
+	case FormatLogfmt:
+		opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+		outHandler, errHandler = slog.NewTextHandler(out, opts), slog.NewTextHandler(err, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q: use %v, %v, or %v",
+			format, FormatText, FormatJSON, FormatLogfmt)
+	}
+	return &Logger{l: slog.New(&splitHandler{
+		level: level,
+		out:   outHandler,
+		err:   errHandler,
+	})}, nil
 }
 
 // NewStdLogger create a Logger that uses out and err for informational and
 // error messages.
 func NewStdLogger(out, err io.Writer) cloudsql.Logger {
-	return &StdLogger{
-		stdLog: llog.New(out, "", llog.LstdFlags),
-		errLog: llog.New(err, "", llog.LstdFlags),
+	l, _ := NewLogger(out, err, FormatText, slog.LevelDebug)
+	return l
+}
+
+// NewStructuredLogger creates a Logger that logs messages using JSON.
+func NewStructuredLogger(quiet bool) cloudsql.Logger {
+	level := slog.LevelDebug
+	if quiet {
+		level = slog.LevelError
 	}
+	l, _ := NewLogger(os.Stdout, os.Stderr, FormatJSON, level)
+	return l
+}
+
+// Debugf logs debug messages
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.l.Debug(fmt.Sprintf(format, v...))
 }
 
 // Infof logs informational messages
-func (l *StdLogger) Infof(format string, v ...interface{}) {
-	l.stdLog.Printf(format, v...)
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.l.Info(fmt.Sprintf(format, v...))
 }
 
 // Errorf logs error messages
-func (l *StdLogger) Errorf(format string, v ...interface{}) {
-	l.errLog.Printf(format, v...)
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.l.Error(fmt.Sprintf(format, v...))
 }
 
-// Debugf logs debug messages
-func (l *StdLogger) Debugf(format string, v ...interface{}) {
-	l.stdLog.Printf(format, v...)
+// Debug logs a debug message with key-value pair fields.
+func (l *Logger) Debug(msg string, args ...any) {
+	l.l.Debug(msg, args...)
 }
 
-// StructuredLogger writes log messages in JSON.
-type StructuredLogger struct {
-	stdLog *slog.Logger
-	errLog *slog.Logger
+// Info logs an informational message with key-value pair fields.
+func (l *Logger) Info(msg string, args ...any) {
+	l.l.Info(msg, args...)
 }
 
-// Infof logs informational messages
-func (l *StructuredLogger) Infof(format string, v ...interface{}) {
-	l.stdLog.Info(fmt.Sprintf(format, v...))
+// Error logs an error message with key-value pair fields.
+func (l *Logger) Error(msg string, args ...any) {
+	l.l.Error(msg, args...)
 }
 
-// Errorf logs error messages
-func (l *StructuredLogger) Errorf(format string, v ...interface{}) {
-	l.errLog.Error(fmt.Sprintf(format, v...))
+// With returns a Logger that adds the key-value pair fields to every
+// message.
+func (l *Logger) With(args ...any) cloudsql.StructuredLogger {
+	return &Logger{l: l.l.With(args...)}
 }
 
-// Debugf logs debug messages
-func (l *StructuredLogger) Debugf(format string, v ...interface{}) {
-	l.stdLog.Debug(fmt.Sprintf(format, v...))
+// splitHandler filters messages below a level and sends error messages to a
+// different handler from all other messages.
+type splitHandler struct {
+	level slog.Level
+	out   slog.Handler
+	err   slog.Handler
 }
 
-// NewStructuredLogger creates a Logger that logs messages using JSON.
-func NewStructuredLogger(quiet bool) cloudsql.Logger {
-	var infoHandler, errorHandler slog.Handler
-	if quiet {
-		infoHandler = slog.DiscardHandler
-	} else {
-		infoHandler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level:       slog.LevelDebug,
-			ReplaceAttr: replaceAttr,
-		})
+func (h *splitHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+func (h *splitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		return h.err.Handle(ctx, r)
+	}
+	return h.out.Handle(ctx, r)
+}
+
+func (h *splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &splitHandler{
+		level: h.level,
+		out:   h.out.WithAttrs(attrs),
+		err:   h.err.WithAttrs(attrs),
+	}
+}
+
+func (h *splitHandler) WithGroup(name string) slog.Handler {
+	return &splitHandler{
+		level: h.level,
+		out:   h.out.WithGroup(name),
+		err:   h.err.WithGroup(name),
 	}
-	errorHandler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		Level:       slog.LevelError,
-		ReplaceAttr: replaceAttr,
+}
+
+// textHandler writes messages in the format of the standard library's log
+// package, followed by any fields, e.g.,
+//
+//	2006/01/02 15:04:05 Accepted connection instance=p:r:i
+type textHandler struct {
+	// mu serializes writes to w, and is shared by all handlers derived from
+	// the same writer.
+	mu    *sync.Mutex
+	w     io.Writer
+	attrs []slog.Attr
+}
+
+func newTextHandler(w io.Writer) *textHandler {
+	return &textHandler{mu: &sync.Mutex{}, w: w}
+}
+
+func (h *textHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var buf bytes.Buffer
+	buf.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	buf.WriteString(r.Message)
+	for _, a := range h.attrs {
+		writeAttr(&buf, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&buf, a)
+		return true
 	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
 
-	l := &StructuredLogger{
-		stdLog: slog.New(infoHandler),
-		errLog: slog.New(errorHandler),
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &textHandler{
+		mu:    h.mu,
+		w:     h.w,
+		attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...),
 	}
-	return l
+}
+
+// WithGroup is not supported by the text format, which ignores groups.
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// writeAttr writes a field as key=value, quoting values with spaces.
+func writeAttr(buf *bytes.Buffer, a slog.Attr) {
+	v := a.Value.Resolve().String()
+	if strings.ContainsAny(v, " \t\n\"=") {
+		v = fmt.Sprintf("%q", v)
+	}
+	fmt.Fprintf(buf, " %s=%s", a.Key, v)
 }
 
 // replaceAttr remaps default Go logging keys to adhere to LogEntry format
@@ -0,0 +1,243 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log //nolint:revive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tcs := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{in: "debug", want: slog.LevelDebug},
+		{in: "info", want: slog.LevelInfo},
+		{in: "WARN", want: slog.LevelWarn},
+		{in: "error", want: slog.LevelError},
+		{in: "verbose", wantErr: true},
+	}
+	for _, tc := range tcs {
+		got, err := ParseLevel(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ParseLevel(%q): want error, got nil", tc.in)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParseLevel(%q): want = %v, got = %v, %v", tc.in, tc.want, got, err)
+		}
+	}
+}
+
+func TestNewLoggerRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewLogger(&bytes.Buffer{}, &bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Fatal("want error for an unknown format, got nil")
+	}
+}
+
+func TestLoggerSplitsErrors(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON, FormatLogfmt} {
+		t.Run(format, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			l, err := NewLogger(&out, &errOut, format, slog.LevelDebug)
+			if err != nil {
+				t.Fatalf("NewLogger error: %v", err)
+			}
+			l.Debugf("debug %d", 1)
+			l.Infof("info %d", 2)
+			l.Errorf("error %d", 3)
+
+			if o := out.String(); !strings.Contains(o, "debug 1") || !strings.Contains(o, "info 2") {
+				t.Fatalf("want debug and info messages on out, got = %q", o)
+			}
+			if strings.Contains(out.String(), "error 3") {
+				t.Fatalf("want no error messages on out, got = %q", out.String())
+			}
+			if e := errOut.String(); !strings.Contains(e, "error 3") || strings.Contains(e, "info 2") {
+				t.Fatalf("want only error messages on err, got = %q", e)
+			}
+		})
+	}
+}
+
+func TestLoggerFiltersLevels(t *testing.T) {
+	tcs := []struct {
+		level     slog.Level
+		wantDebug bool
+		wantInfo  bool
+	}{
+		{level: slog.LevelDebug, wantDebug: true, wantInfo: true},
+		{level: slog.LevelInfo, wantInfo: true},
+		{level: slog.LevelError},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			l, err := NewLogger(&out, &errOut, FormatText, tc.level)
+			if err != nil {
+				t.Fatalf("NewLogger error: %v", err)
+			}
+			l.Debug("debug message")
+			l.Info("info message")
+			l.Error("error message")
+
+			if got := strings.Contains(out.String(), "debug message"); got != tc.wantDebug {
+				t.Errorf("debug message logged: want = %v, got = %v", tc.wantDebug, got)
+			}
+			if got := strings.Contains(out.String(), "info message"); got != tc.wantInfo {
+				t.Errorf("info message logged: want = %v, got = %v", tc.wantInfo, got)
+			}
+			// Errors are never filtered.
+			if !strings.Contains(errOut.String(), "error message") {
+				t.Errorf("want error message logged, got = %q", errOut.String())
+			}
+		})
+	}
+}
+
+func TestLoggerTextFormat(t *testing.T) {
+	var out bytes.Buffer
+	l, err := NewLogger(&out, &bytes.Buffer{}, FormatText, slog.LevelDebug)
+	if err != nil {
+		t.Fatalf("NewLogger error: %v", err)
+	}
+	l.With("instance", "p:r:i").Info("Accepted connection", "client", "127.0.0.1:5000", "reason", "idle timeout")
+
+	re := regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} Accepted connection ` +
+		`instance=p:r:i client=127.0.0.1:5000 reason="idle timeout"\n$`)
+	if !re.MatchString(out.String()) {
+		t.Fatalf("unexpected text output: %q", out.String())
+	}
+}
+
+func TestLoggerJSONFormat(t *testing.T) {
+	var out bytes.Buffer
+	l, err := NewLogger(&out, &bytes.Buffer{}, FormatJSON, slog.LevelDebug)
+	if err != nil {
+		t.Fatalf("NewLogger error: %v", err)
+	}
+	l.With("instance", "p:r:i").Info("Accepted connection", "conn_id", 7)
+
+	var got map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal error: %v, output = %q", err, out.String())
+	}
+	want := map[string]any{
+		"severity": "INFO",
+		"message":  "Accepted connection",
+		"instance": "p:r:i",
+		"conn_id":  float64(7),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%v: want = %v, got = %v", k, v, got[k])
+		}
+	}
+	ts, ok := got["timestamp"].(string)
+	if !ok {
+		t.Fatalf("want a timestamp, got = %v", got["timestamp"])
+	}
+	if _, err := time.Parse(time.RFC3339, ts); err != nil {
+		t.Errorf("want an RFC 3339 timestamp, got = %q", ts)
+	}
+}
+
+func TestLoggerWithDoesNotShareFields(t *testing.T) {
+	var out bytes.Buffer
+	l, err := NewLogger(&out, &bytes.Buffer{}, FormatText, slog.LevelDebug)
+	if err != nil {
+		t.Fatalf("NewLogger error: %v", err)
+	}
+	base := l.With("instance", "p:r:i")
+	a := base.With("conn_id", 1)
+	b := base.With("conn_id", 2)
+	a.Info("a")
+	b.Info("b")
+	base.Info("base")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("want 3 lines, got = %q", out.String())
+	}
+	for i, want := range []string{
+		" a instance=p:r:i conn_id=1",
+		" b instance=p:r:i conn_id=2",
+		" base instance=p:r:i",
+	} {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("line %d: want suffix %q, got = %q", i, want, lines[i])
+		}
+	}
+}
+
+func TestSplitHandler(t *testing.T) {
+	var out, errOut bytes.Buffer
+	h := &splitHandler{
+		level: slog.LevelInfo,
+		out:   newTextHandler(&out),
+		err:   newTextHandler(&errOut),
+	}
+	ctx := context.Background()
+	if h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("want debug disabled below the level")
+	}
+	if !h.Enabled(ctx, slog.LevelInfo) || !h.Enabled(ctx, slog.LevelError) {
+		t.Error("want info and error enabled at or above the level")
+	}
+
+	// Fields added to the split handler reach both handlers.
+	hf := h.WithAttrs([]slog.Attr{slog.String("instance", "p:r:i")})
+	for _, lvl := range []slog.Level{slog.LevelWarn, slog.LevelError} {
+		r := slog.NewRecord(time.Now(), lvl, lvl.String(), 0)
+		if err := hf.Handle(ctx, r); err != nil {
+			t.Fatalf("Handle error: %v", err)
+		}
+	}
+	if got := out.String(); !strings.Contains(got, "WARN instance=p:r:i") || strings.Contains(got, "ERROR") {
+		t.Errorf("want only the warning on out, got = %q", got)
+	}
+	if got := errOut.String(); !strings.Contains(got, "ERROR instance=p:r:i") || strings.Contains(got, "WARN") {
+		t.Errorf("want only the error on err, got = %q", got)
+	}
+}
+
+func TestTextHandlerQuotesValues(t *testing.T) {
+	var out bytes.Buffer
+	h := newTextHandler(&out)
+	r := slog.NewRecord(time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local), slog.LevelInfo, "msg", 0)
+	r.AddAttrs(
+		slog.String("plain", "value"),
+		slog.String("space", "two words"),
+		slog.String("equals", "a=b"),
+		slog.Int("n", 3),
+	)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatalf("Handle error: %v", err)
+	}
+	want := `2026/01/02 03:04:05 msg plain=value space="two words" equals="a=b" n=3` + "\n"
+	if got := out.String(); got != want {
+		t.Fatalf("want = %q, got = %q", want, got)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// connLimiter caps the number of concurrent connections. Connections that
+// exceed the cap may wait in a queue for a free slot. A nil connLimiter
+// imposes no limit.
+type connLimiter struct {
+	// max is the maximum number of concurrent connections.
+	max uint64
+	// slots holds one value per admitted connection.
+	slots chan struct{}
+	// queued is the number of connections waiting for a free slot.
+	queued atomic.Uint64
+}
+
+// newConnLimiter returns a connLimiter for max connections, or nil if max is
+// zero.
+func newConnLimiter(max uint64) *connLimiter {
+	if max == 0 {
+		return nil
+	}
+	return &connLimiter{max: max, slots: make(chan struct{}, max)}
+}
+
+// acquire reserves a slot, waiting up to wait for one to become free. It
+// reports how long the caller waited and whether a slot was reserved.
+func (l *connLimiter) acquire(wait time.Duration) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return 0, true
+	default:
+	}
+	if wait <= 0 {
+		return 0, false
+	}
+
+	l.queued.Add(1)
+	defer l.queued.Add(^uint64(0))
+	start := time.Now()
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return time.Since(start), true
+	case <-t.C:
+		return time.Since(start), false
+	}
+}
+
+// release frees a slot reserved with acquire.
+func (l *connLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// limit returns the maximum number of connections, or zero for no limit.
+func (l *connLimiter) limit() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.max
+}
+
+// queueLen returns the number of connections waiting for a free slot.
+func (l *connLimiter) queueLen() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.queued.Load()
+}
@@ -0,0 +1,147 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// activeConn is a proxied connection tracked by a connRegistry.
+type activeConn struct {
+	id         uint64
+	inst       string
+	clientAddr string
+	start      time.Time
+	// bytesSent counts bytes copied from the client to the instance.
+	bytesSent atomic.Uint64
+	// bytesReceived counts bytes copied from the instance to the client.
+	bytesReceived atomic.Uint64
+	// session follows the database protocol of the connection, if
+	// transaction-aware shutdown is enabled.
+	session sessionTracker
+	// close forcibly closes the connection, logging desc and recording
+	// reason as the reason for closing.
+	close func(desc, reason string)
+}
+
+// connRegistry tracks the active connections of a Client. The zero value is
+// ready to use.
+type connRegistry struct {
+	// nextID is the last assigned connection ID.
+	nextID atomic.Uint64
+	mu     sync.Mutex
+	conns  map[uint64]*activeConn
+}
+
+// newConn returns an entry for a new connection with a unique ID. The entry
+// is not tracked until it is passed to add.
+func (r *connRegistry) newConn(inst string, clientAddr net.Addr) *activeConn {
+	ac := &activeConn{
+		id:    r.nextID.Add(1),
+		inst:  inst,
+		start: time.Now(),
+	}
+	if clientAddr != nil {
+		ac.clientAddr = clientAddr.String()
+	}
+	return ac
+}
+
+// add starts tracking a connection.
+func (r *connRegistry) add(ac *activeConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conns == nil {
+		r.conns = make(map[uint64]*activeConn)
+	}
+	r.conns[ac.id] = ac
+}
+
+// remove unregisters a connection.
+func (r *connRegistry) remove(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, id)
+}
+
+// list returns details of all active connections, ordered by ID.
+func (r *connRegistry) list() []ConnInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]ConnInfo, 0, len(r.conns))
+	for _, ac := range r.conns {
+		infos = append(infos, ConnInfo{
+			ID:            ac.id,
+			Instance:      ac.inst,
+			ClientAddr:    ac.clientAddr,
+			StartTime:     ac.start,
+			BytesSent:     ac.bytesSent.Load(),
+			BytesReceived: ac.bytesReceived.Load(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// closeConn forcibly closes the connection with the provided ID.
+func (r *connRegistry) closeConn(id uint64) error {
+	r.mu.Lock()
+	ac, ok := r.conns[id]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("connection %v not found", id)
+	}
+	ac.close("connection closed by admin request", closeReasonAdmin)
+	return nil
+}
+
+// closeIdle closes every connection whose database session is idle outside
+// of a transaction, and reports how many connections it closed.
+func (r *connRegistry) closeIdle() int {
+	r.mu.Lock()
+	var idle []*activeConn
+	for _, ac := range r.conns {
+		if ac.session != nil && ac.session.idle() {
+			idle = append(idle, ac)
+		}
+	}
+	r.mu.Unlock()
+	for _, ac := range idle {
+		ac.close("closing idle connection for shutdown", closeReasonShutdown)
+	}
+	return len(idle)
+}
+
+// ConnInfo describes an active proxied connection.
+type ConnInfo struct {
+	// ID uniquely identifies the connection for the life of the Client.
+	ID uint64 `json:"id"`
+	// Instance is the instance connection name.
+	Instance string `json:"instance"`
+	// ClientAddr is the remote address of the client.
+	ClientAddr string `json:"clientAddress"`
+	// StartTime is when the connection to the instance was established.
+	StartTime time.Time `json:"startTime"`
+	// BytesSent is the number of bytes sent from the client to the instance.
+	BytesSent uint64 `json:"bytesSent"`
+	// BytesReceived is the number of bytes received from the instance for
+	// the client.
+	BytesReceived uint64 `json:"bytesReceived"`
+}
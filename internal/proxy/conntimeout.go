@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// connTimeouts holds the effective idle timeout and maximum lifetime for
+// connections to an instance. A zero value disables the limit.
+type connTimeouts struct {
+	idle        time.Duration
+	maxLifetime time.Duration
+}
+
+// newConnTimeouts resolves the connection timeouts for an instance, where
+// instance settings take precedence over global settings.
+func newConnTimeouts(c *Config, i InstanceConnConfig) connTimeouts {
+	t := connTimeouts{
+		idle:        c.IdleTimeout,
+		maxLifetime: c.MaxConnLifetime,
+	}
+	if i.IdleTimeout != nil {
+		t.idle = *i.IdleTimeout
+	}
+	if i.MaxConnLifetime != nil {
+		t.maxLifetime = *i.MaxConnLifetime
+	}
+	return t
+}
+
+// enabled reports whether either limit is set.
+func (t connTimeouts) enabled() bool {
+	return t.idle > 0 || t.maxLifetime > 0
+}
+
+// activity records the time of the last byte seen on a connection.
+type activity struct {
+	last atomic.Int64
+}
+
+// touch marks the connection as active now.
+func (a *activity) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+// lastActive returns the time of the last byte seen on the connection.
+func (a *activity) lastActive() time.Time {
+	return time.Unix(0, a.last.Load())
+}
+
+// watch waits until the connection exceeds one of the limits or done is
+// closed. It returns the reason the limit was exceeded, or an empty string
+// if done was closed first.
+func (t connTimeouts) watch(start time.Time, a *activity, done <-chan struct{}) string {
+	timer := time.NewTimer(t.next(start, a.lastActive(), time.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-done:
+			return ""
+		case <-timer.C:
+		}
+		now := time.Now()
+		if t.maxLifetime > 0 && now.Sub(start) >= t.maxLifetime {
+			return closeReasonMaxLifetime
+		}
+		if t.idle > 0 && now.Sub(a.lastActive()) >= t.idle {
+			return closeReasonIdleTimeout
+		}
+		timer.Reset(t.next(start, a.lastActive(), now))
+	}
+}
+
+// next returns how long to wait before a limit could next be exceeded.
+func (t connTimeouts) next(start, lastActive, now time.Time) time.Duration {
+	var d time.Duration
+	if t.idle > 0 {
+		d = lastActive.Add(t.idle).Sub(now)
+	}
+	if t.maxLifetime > 0 {
+		if l := start.Add(t.maxLifetime).Sub(now); t.idle <= 0 || l < d {
+			d = l
+		}
+	}
+	return d
+}
@@ -0,0 +1,153 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// copyBufSize is the size of the buffers used to copy between connections.
+const copyBufSize = 32 * 1024
+
+// bufPool holds buffers for copying between connections, so that each new
+// connection does not allocate its own.
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, copyBufSize)
+		return &b
+	},
+}
+
+// closeWriter is implemented by connections that support half-close, e.g.,
+// *net.TCPConn, *net.UnixConn, and *tls.Conn.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// closeWriterOf returns the closeWriter for conn, looking through wrappers
+// that embed a net.Conn, e.g., the instrumented connections the Cloud SQL Go
+// Connector returns around a *tls.Conn. Only CloseWrite bypasses the wrapper.
+func closeWriterOf(conn net.Conn) (closeWriter, bool) {
+	for conn != nil {
+		if cw, ok := conn.(closeWriter); ok {
+			return cw, true
+		}
+		conn = embeddedConn(conn)
+	}
+	return nil, false
+}
+
+// embeddedConn returns the net.Conn embedded in the struct behind conn, or nil
+// if there is none.
+func embeddedConn(conn net.Conn) net.Conn {
+	v := reflect.ValueOf(conn)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	f, ok := v.Type().FieldByName("Conn")
+	if !ok || !f.Anonymous || len(f.Index) != 1 {
+		return nil
+	}
+	inner, _ := v.Field(f.Index[0]).Interface().(net.Conn)
+	return inner
+}
+
+// readError is an error from reading the source connection of a copy.
+type readError struct{ err error }
+
+func (e *readError) Error() string { return e.err.Error() }
+func (e *readError) Unwrap() error { return e.err }
+
+// writeError is an error from writing the destination connection of a copy.
+type writeError struct{ err error }
+
+func (e *writeError) Error() string { return e.err.Error() }
+func (e *writeError) Unwrap() error { return e.err }
+
+// copyConn copies from src to dst through a pooled buffer until src reaches
+// EOF or an error occurs. A clean EOF returns a nil error. Errors are wrapped
+// in a readError or writeError depending on the failing side.
+//
+// If a is not nil, it is updated whenever data is read from src. If watch is
+// not nil, it observes every byte read from src. If n is not nil, it counts
+// the bytes copied as they are read.
+func copyConn(dst, src net.Conn, a *activity, n *atomic.Uint64, watch io.Writer) error {
+	bp := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bp)
+	r := &trackedReader{r: src, a: a, n: n, watch: watch}
+	w := &trackedWriter{w: dst}
+	_, err := io.CopyBuffer(w, r, *bp)
+	switch {
+	case err == nil:
+		return nil
+	case r.err != nil:
+		return &readError{err: r.err}
+	case w.err != nil:
+		return &writeError{err: w.err}
+	default:
+		// e.g., io.ErrShortWrite
+		return &writeError{err: err}
+	}
+}
+
+// trackedReader records read errors, activity, and bytes read. It hides any WriterTo
+// implementation of the underlying reader so that copies use the pooled
+// buffer.
+type trackedReader struct {
+	r     io.Reader
+	a     *activity
+	n     *atomic.Uint64
+	watch io.Writer
+	err   error
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 && t.a != nil {
+		t.a.touch()
+	}
+	if n > 0 && t.n != nil {
+		t.n.Add(uint64(n))
+	}
+	if n > 0 && t.watch != nil {
+		_, _ = t.watch.Write(p[:n])
+	}
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
+
+// trackedWriter records write errors. It hides any ReaderFrom implementation
+// of the underlying writer so that copies use the pooled buffer.
+type trackedWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *trackedWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil {
+		t.err = err
+	}
+	return n, err
+}
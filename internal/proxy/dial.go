@@ -0,0 +1,163 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/cloudsqlconn/errtype"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// defaultDialTimeout is the time allowed for a single dial attempt when
+	// no timeout is configured.
+	defaultDialTimeout = 30 * time.Second
+	// defaultDialRetryBackoff is the initial delay between dial attempts when
+	// no backoff is configured.
+	defaultDialRetryBackoff = 500 * time.Millisecond
+	// maxDialRetryBackoff caps the delay between dial attempts.
+	maxDialRetryBackoff = 30 * time.Second
+)
+
+// dialSettings holds the effective dial timeout and retry settings for an
+// instance.
+type dialSettings struct {
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+// newDialSettings resolves the dial settings for an instance, where instance
+// settings take precedence over global settings.
+func newDialSettings(c *Config, i InstanceConnConfig) dialSettings {
+	s := dialSettings{
+		timeout: c.DialTimeout,
+		retries: c.DialRetries,
+		backoff: c.DialRetryBackoff,
+	}
+	if i.DialTimeout != nil {
+		s.timeout = *i.DialTimeout
+	}
+	if i.DialRetries != nil {
+		s.retries = *i.DialRetries
+	}
+	if i.DialRetryBackoff != nil {
+		s.backoff = *i.DialRetryBackoff
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultDialTimeout
+	}
+	if s.backoff <= 0 {
+		s.backoff = defaultDialRetryBackoff
+	}
+	return s
+}
+
+// dial connects to the socket mount's instance, retrying with exponential
+// backoff and jitter when the error may be temporary. It stops retrying once
+// ctx is done, the Client is closing, or the instance is draining.
+func (c *Client) dial(ctx context.Context, s *socketMount) (net.Conn, error) {
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		conn, err := c.dialOnce(ctx, s)
+		c.recordDialResult(err)
+		if err == nil {
+			recordDialLatency(s.inst, time.Since(start))
+			return conn, nil
+		}
+		recordDialError(s.inst, err)
+		if attempt >= s.dialSettings.retries || !isRetryable(err) || !c.retrying(s) {
+			return nil, err
+		}
+
+		d := retryBackoff(s.dialSettings.backoff, attempt)
+		s.logger.Debugf("dial attempt %d failed, retrying in %v: %v",
+			attempt+1, d, err)
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		case <-c.closing:
+			t.Stop()
+			return nil, err
+		}
+		if !c.retrying(s) {
+			return nil, err
+		}
+	}
+}
+
+// retrying reports whether a failed dial to the socket mount's instance may
+// be retried, which it may not once the Client is closing or the instance is
+// draining.
+func (c *Client) retrying(s *socketMount) bool {
+	select {
+	case <-c.closing:
+		return false
+	default:
+	}
+	return c.accepting(s.inst)
+}
+
+// dialOnce makes a single dial attempt bounded by the dial timeout.
+func (c *Client) dialOnce(ctx context.Context, s *socketMount) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.dialSettings.timeout)
+	defer cancel()
+	return c.dialer.Dial(ctx, s.inst, s.dialOpts...)
+}
+
+// retryBackoff returns a random delay between zero and the exponential
+// backoff for the attempt, capped at maxDialRetryBackoff.
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	d := base << attempt
+	if d <= 0 || d > maxDialRetryBackoff {
+		d = maxDialRetryBackoff
+	}
+	return rand.N(d) + 1
+}
+
+// isRetryable reports whether a dial error may succeed on a later attempt.
+// Configuration, authentication, and authorization errors, along with
+// instances that do not exist, fail the same way every time.
+func isRetryable(err error) bool {
+	var (
+		cfgErr    *errtype.ConfigError
+		reErr     *errtype.ResourceExhaustedError
+		apiErr    *googleapi.Error
+		oauth2Err *oauth2.RetrieveError
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &cfgErr), errors.As(err, &reErr), errors.As(err, &oauth2Err):
+		return false
+	case errors.As(err, &apiErr):
+		switch apiErr.Code {
+		case http.StatusBadRequest, http.StatusUnauthorized,
+			http.StatusForbidden, http.StatusNotFound:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// dirLock is an exclusive lock on a Unix socket directory, held until the
+// Client closes.
+type dirLock struct {
+	f    *os.File
+	once sync.Once
+}
+
+// lockDir locks the directory with a lock file holding the PID of the
+// process. The lock is released when the process exits, so a lock file left
+// behind by a crashed process does not block a new one.
+func lockDir(dir string) (*dirLock, error) {
+	p := filepath.Join(dir, unixSocketLockFile)
+	for {
+		f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			pid, _ := io.ReadAll(f)
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, fmt.Errorf(
+					"%v is in use by another Cloud SQL Proxy (pid %v)",
+					dir, strings.TrimSpace(string(pid)),
+				)
+			}
+			return nil, err
+		}
+		// The previous holder removes the file before releasing the lock, so
+		// the locked file may no longer be the one at the path. Try again
+		// until both are the same.
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if pfi, err := os.Stat(p); err != nil || !os.SameFile(fi, pfi) {
+			f.Close()
+			continue
+		}
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := fmt.Fprintf(f, "%d\n", os.Getpid()); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &dirLock{f: f}, nil
+	}
+}
+
+// unlock removes the lock file and releases the lock. It is safe to call
+// more than once and on a nil lock.
+func (l *dirLock) unlock() error {
+	if l == nil {
+		return nil
+	}
+	var err error
+	l.once.Do(func() {
+		// Remove the file while still holding the lock, so no other process
+		// can lock it first.
+		_ = os.Remove(l.f.Name())
+		err = l.f.Close()
+	})
+	return err
+}
@@ -0,0 +1,60 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import "sync"
+
+// drainState records that the Client is draining and which instances no
+// longer accept new connections.
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	// all is set when every instance stops accepting, including FUSE
+	// sockets created later.
+	all   bool
+	insts map[string]bool
+}
+
+// Drain marks the Client as draining, which fails the readiness check, and
+// stops accepting new connections for the named instances, or for every
+// instance if all is set. Unlike RemoveInstance, the sockets stay mounted:
+// new connections are closed as soon as they are accepted, and open
+// connections keep running. Drain works in FUSE mode.
+func (c *Client) Drain(all bool, names ...string) {
+	c.drain.mu.Lock()
+	defer c.drain.mu.Unlock()
+	c.drain.draining = true
+	c.drain.all = c.drain.all || all
+	for _, n := range names {
+		if c.drain.insts == nil {
+			c.drain.insts = make(map[string]bool)
+		}
+		c.drain.insts[n] = true
+	}
+}
+
+// Draining reports whether Drain has been called.
+func (c *Client) Draining() bool {
+	c.drain.mu.Lock()
+	defer c.drain.mu.Unlock()
+	return c.drain.draining
+}
+
+// accepting reports whether the instance accepts new connections.
+func (c *Client) accepting(inst string) bool {
+	c.drain.mu.Lock()
+	defer c.drain.mu.Unlock()
+	return !c.drain.all && !c.drain.insts[inst]
+}
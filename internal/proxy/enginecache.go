@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+)
+
+// engineCache persists the engine version of instances to a file, so that
+// sockets can be mounted at startup without calling the Admin API.
+type engineCache struct {
+	path   string
+	logger cloudsql.Logger
+
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// loadEngineCache reads the engine versions cached at path. A missing or
+// unreadable file starts an empty cache.
+func loadEngineCache(path string, l cloudsql.Logger) *engineCache {
+	c := &engineCache{path: path, logger: l, versions: make(map[string]string)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &c.versions)
+	}
+	if err != nil {
+		l.Errorf("Ignoring engine version cache %v: %v", path, err)
+		c.versions = make(map[string]string)
+	}
+	return c
+}
+
+// get returns the cached engine version of the instance.
+func (c *engineCache) get(inst string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.versions[inst]
+	return v, ok
+}
+
+// put caches the engine version of the instance, writing the file if the
+// version changed.
+func (c *engineCache) put(inst, version string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.versions[inst] == version {
+		return
+	}
+	c.versions[inst] = version
+	if err := c.write(); err != nil {
+		c.logger.Errorf("Failed to write engine version cache %v: %v", c.path, err)
+	}
+}
+
+// write replaces the cache file with the cached versions. The caller must
+// hold mu.
+func (c *engineCache) write() error {
+	b, err := json.MarshalIndent(c.versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so a reader never sees a partially
+	// written file.
+	f, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path)
+}
+
+// engineVersion resolves the database engine version of an instance. An
+// engine set on the instance or implied by SQL Data takes precedence, then
+// the engine version cache, and finally the dialer, which calls the Admin
+// API.
+func (c *Client) engineVersion(ctx context.Context, conf *Config, inst InstanceConnConfig) (string, error) {
+	switch {
+	case inst.Engine != "":
+		return inst.Engine, nil
+	case conf.SQLDataEnabled || inst.SQLDataEnabled != nil && *inst.SQLDataEnabled:
+		// TODO: Only Postgres is supported by the SqlDataService
+		// when more engines are supported, this code will need to change.
+		return "POSTGRES", nil
+	}
+	if v, ok := c.engineCache.get(inst.Name); ok {
+		return v, nil
+	}
+	v, err := c.dialer.EngineVersion(ctx, inst.Name)
+	if err != nil {
+		return "", err
+	}
+	c.engineCache.put(inst.Name, v)
+	return v, nil
+}
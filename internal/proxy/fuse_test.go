@@ -21,6 +21,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 	"time"
 
@@ -43,8 +44,12 @@ func randTmpDir(t interface {
 // proxy.Client and starts it. The returned cleanup function is also a
 // convenience. Callers may choose to ignore it and manually close the client.
 func newTestClient(t *testing.T, d cloudsql.Dialer, fuseDir, fuseTempDir string) (*proxy.Client, chan error, func()) {
-	conf := &proxy.Config{FUSEDir: fuseDir, FUSETempDir: fuseTempDir}
+	return newTestClientWithConfig(t, d, &proxy.Config{FUSEDir: fuseDir, FUSETempDir: fuseTempDir})
+}
 
+// newTestClientWithConfig is like newTestClient but uses the provided
+// configuration.
+func newTestClientWithConfig(t *testing.T, d cloudsql.Dialer, conf *proxy.Config) (*proxy.Client, chan error, func()) {
 	// This context is only used to call the Cloud SQL API
 	c, err := proxy.NewClient(context.Background(), d, testLogger, conf, nil)
 	if err != nil {
@@ -359,6 +364,72 @@ func TestFUSEErrors(t *testing.T) {
 	t.Fatalf("engine version attempts: want = %v, got = %v", wantAttempts, attempts)
 }
 
+func TestFUSEAllowAndDeny(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping fuse tests in short mode.")
+	}
+	if os.Getenv("SKIP_FUSE_E2E_TESTS") == "true" {
+		t.Skip("skipping Postgres FUSE integration tests because SKIP_FUSE_E2E_TESTS is set")
+	}
+	d := &fakeDialer{}
+	c, _, cleanup := newTestClientWithConfig(t, d, &proxy.Config{
+		FUSEDir:     randTmpDir(t),
+		FUSETempDir: randTmpDir(t),
+		FUSEAllow:   []string{"proj:*:*"},
+		FUSEDeny:    []string{"proj:*:secret"},
+	})
+	defer cleanup()
+
+	tcs := []struct {
+		inst string
+		want syscall.Errno
+	}{
+		{inst: "proj:reg:mysql", want: fs.OK},
+		{inst: "proj:reg:secret", want: syscall.ENOENT},
+		{inst: "other:reg:mysql", want: syscall.ENOENT},
+	}
+	for _, tc := range tcs {
+		if _, got := c.Lookup(context.Background(), tc.inst, nil); got != tc.want {
+			t.Errorf("Lookup(%q): want = %v, got = %v", tc.inst, tc.want, got)
+		}
+	}
+	if got := d.engineVersionAttempts(); got != 1 {
+		t.Fatalf("engine version attempts: want = 1, got = %v", got)
+	}
+}
+
+func TestFUSEIdleTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping fuse tests in short mode.")
+	}
+	if os.Getenv("SKIP_FUSE_E2E_TESTS") == "true" {
+		t.Skip("skipping Postgres FUSE integration tests because SKIP_FUSE_E2E_TESTS is set")
+	}
+	ctx := context.Background()
+	d := &fakeDialer{}
+	c, _, cleanup := newTestClientWithConfig(t, d, &proxy.Config{
+		FUSEDir:         randTmpDir(t),
+		FUSETempDir:     randTmpDir(t),
+		FUSEIdleTimeout: 100 * time.Millisecond,
+	})
+	defer cleanup()
+
+	if _, err := c.Lookup(ctx, "proj:reg:mysql", nil); err != fs.OK {
+		t.Fatalf("proxy.Client.Lookup(): %v", err)
+	}
+	// Wait for the idle socket to be removed.
+	time.Sleep(300 * time.Millisecond)
+	if _, err := c.Lookup(ctx, "proj:reg:mysql", nil); err != fs.OK {
+		t.Fatalf("proxy.Client.Lookup(): %v", err)
+	}
+
+	// Verify the dialer was called twice, to prove the idle socket was
+	// removed and a new one created.
+	if got := d.engineVersionAttempts(); got != 2 {
+		t.Fatalf("engine version attempts: want = 2, got = %v", got)
+	}
+}
+
 func TestFUSEWithBadInstanceName(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping fuse tests in short mode.")
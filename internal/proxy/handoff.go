@@ -0,0 +1,143 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// filer is implemented by listeners that can return a copy of their file
+// descriptor.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// ListenerFile is a copy of the listener of a mounted socket.
+type ListenerFile struct {
+	// Name is the instance connection name the listener serves.
+	Name string
+	// File is a copy of the listener's file descriptor.
+	File *os.File
+	// Activated is set when the listener was passed by socket activation,
+	// rather than created by the Proxy.
+	Activated bool
+}
+
+// ListenerFiles returns a copy of the listener of every mounted socket for
+// the configured instances, so that another process started with the same
+// configuration can accept connections on them. Instances added with
+// AddInstance are not included, and their sockets are removed by
+// StopAccepting. The caller must close the files. It is not supported in
+// FUSE mode.
+func (c *Client) ListenerFiles() ([]ListenerFile, error) {
+	if c.fuseDir != "" {
+		return nil, errors.New("cannot hand off listeners in FUSE mode")
+	}
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	var lfs []ListenerFile
+	for _, m := range c.mnts {
+		if !c.configured[m.inst] {
+			m.handedOver.Store(false)
+			continue
+		}
+		f, err := m.file()
+		if err != nil {
+			for _, lf := range lfs {
+				lf.File.Close()
+			}
+			return nil, fmt.Errorf("[%v] unable to copy listener: %v", m.inst, err)
+		}
+		m.handedOver.Store(true)
+		lfs = append(lfs, ListenerFile{Name: m.inst, File: f, Activated: m.activated})
+	}
+	return lfs, nil
+}
+
+// file returns a copy of the mount's listener file descriptor.
+func (s *socketMount) file() (*os.File, error) {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	f, ok := s.listener.(filer)
+	if !ok {
+		return nil, fmt.Errorf("listener %T cannot be handed off", s.listener)
+	}
+	return f.File()
+}
+
+// keepSocket leaves a Unix socket in place when the mount is closed, so
+// another process can keep accepting connections on it.
+func (s *socketMount) keepSocket() {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	if ul, ok := s.listener.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+}
+
+// StopAccepting stops accepting new connections on every mounted socket and
+// stops mounting instances, leaving the sockets returned by ListenerFiles in
+// place for the process that took over the listeners. Open connections keep
+// running until Close.
+func (c *Client) StopAccepting() {
+	c.mntsMu.Lock()
+	mnts := c.mnts
+	c.mnts = nil
+	c.pending = nil
+	c.handedOff = true
+	c.mntsMu.Unlock()
+	for _, m := range mnts {
+		m.removed.Store(true)
+		if m.handedOver.Load() {
+			m.keepSocket()
+		}
+		if err := m.Close(); err != nil {
+			m.logger.Errorf("Failed to stop listening: %v", err)
+		}
+		m.logger.Infof(
+			"Stopped listening on %s, draining %d open connection(s)",
+			m.Addr(), m.connCount.Load(),
+		)
+	}
+}
+
+// UnlockUnixSocketDir releases the lock on the Unix socket directory, if one
+// is held, so the process taking over the listeners can lock it.
+func (c *Client) UnlockUnixSocketDir() error {
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	err := c.dirLock.unlock()
+	c.dirLock = nil
+	return err
+}
+
+// LockUnixSocketDir locks the Unix socket directory again after
+// UnlockUnixSocketDir, if the lock is configured.
+func (c *Client) LockUnixSocketDir() error {
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	if !c.conf.UnixSocketLock || c.conf.UnixSocket == "" || c.dirLock != nil {
+		return nil
+	}
+	dl, err := lockDir(c.conf.UnixSocket)
+	if err != nil {
+		return err
+	}
+	c.dirLock = dl
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Health reports the signals used to decide whether the Client is wedged.
+type Health struct {
+	// Mounts is the number of mounted sockets.
+	Mounts int
+	// Serving is the number of mounted sockets accepting connections.
+	Serving int
+	// DialFailingSince is when every dial started failing. It is zero if the
+	// last dial succeeded or no dial has failed.
+	DialFailingSince time.Time
+	// DialLastFailed is when the last dial failed, if DialFailingSince is
+	// set.
+	DialLastFailed time.Time
+	// AcceptFailingSince is when the longest failing accept loop started
+	// failing. It is zero if no accept loop is failing.
+	AcceptFailingSince time.Time
+	// AcceptLastFailed is when that accept loop last failed, if
+	// AcceptFailingSince is set.
+	AcceptLastFailed time.Time
+}
+
+// failureRun tracks a run of consecutive failures, so that a single failure
+// followed by no further attempts is not mistaken for a lasting one.
+type failureRun struct {
+	// since is when the run started, in Unix nanoseconds, or zero if the
+	// last attempt succeeded.
+	since atomic.Int64
+	// last is when the latest failure of the run happened, in Unix
+	// nanoseconds.
+	last atomic.Int64
+}
+
+// fail records a failure.
+func (f *failureRun) fail() {
+	now := time.Now().UnixNano()
+	f.last.Store(now)
+	f.since.CompareAndSwap(0, now)
+}
+
+// succeed ends the run of failures.
+func (f *failureRun) succeed() {
+	f.since.Store(0)
+}
+
+// times returns when the run started and when it last failed. Both are zero
+// if there is no run of failures.
+func (f *failureRun) times() (time.Time, time.Time) {
+	since := f.since.Load()
+	if since == 0 {
+		return time.Time{}, time.Time{}
+	}
+	last := f.last.Load()
+	if last < since {
+		last = since
+	}
+	return time.Unix(0, since), time.Unix(0, last)
+}
+
+// Health reports whether the mounted sockets are serving, and for how long
+// dials and accepts have been failing.
+func (c *Client) Health() Health {
+	var h Health
+	h.DialFailingSince, h.DialLastFailed = c.dialFailures.times()
+	for _, m := range c.mounts() {
+		h.Mounts++
+		if m.serving.Load() {
+			h.Serving++
+		}
+		since, last := m.acceptFailures.times()
+		if since.IsZero() {
+			continue
+		}
+		if h.AcceptFailingSince.IsZero() ||
+			last.Sub(since) > h.AcceptLastFailed.Sub(h.AcceptFailingSince) {
+			h.AcceptFailingSince, h.AcceptLastFailed = since, last
+		}
+	}
+	return h
+}
+
+// recordDialResult tracks how long every dial has been failing.
+func (c *Client) recordDialResult(err error) {
+	if err == nil {
+		c.dialFailures.succeed()
+		return
+	}
+	c.dialFailures.fail()
+}
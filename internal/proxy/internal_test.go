@@ -15,10 +15,33 @@
 package proxy
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 	"unsafe"
 
+	"cloud.google.com/go/cloudsqlconn/errtype"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/log"
 	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	"google.golang.org/api/googleapi"
 )
 
 func TestClientUsesSyncAtomicAlignment(t *testing.T) {
@@ -66,6 +89,536 @@ func TestParseImpersonationChain(t *testing.T) {
 	}
 }
 
+func TestFUSEInstanceConfig(t *testing.T) {
+	pTrue, pFalse := true, false
+	conf := &Config{FUSEInstanceRules: []FUSEInstanceRule{
+		{
+			Pattern:  "proj:us-central1:*",
+			Settings: InstanceConnConfig{Name: "proj:us-central1:*", PSC: &pTrue},
+		},
+		{
+			Pattern: "proj:*:*",
+			Settings: InstanceConnConfig{
+				Name: "proj:*:*", Port: 5000, PrivateIP: &pTrue, IAMAuthN: &pFalse,
+			},
+		},
+	}}
+	tcs := []struct {
+		desc string
+		inst string
+		want InstanceConnConfig
+	}{
+		{
+			desc: "first matching rule applies",
+			inst: "proj:us-central1:db",
+			want: InstanceConnConfig{Name: "proj:us-central1:db", PSC: &pTrue},
+		},
+		{
+			desc: "socket settings are ignored",
+			inst: "proj:europe-west1:db",
+			want: InstanceConnConfig{
+				Name: "proj:europe-west1:db", PrivateIP: &pTrue, IAMAuthN: &pFalse,
+			},
+		},
+		{
+			desc: "no matching rule",
+			inst: "other:us-central1:db",
+			want: InstanceConnConfig{Name: "other:us-central1:db"},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := conf.fuseInstanceConfig(tc.inst)
+			if !cmp.Equal(tc.want, got) {
+				t.Fatalf("want != got (-want +got):\n%v", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+
+	if conf.iamAuthNEnabled() {
+		t.Fatal("want IAM authentication disabled")
+	}
+	conf.FUSEInstanceRules[0].Settings.IAMAuthN = &pTrue
+	if !conf.iamAuthNEnabled() {
+		t.Fatal("want IAM authentication enabled by a rule")
+	}
+}
+
+func TestFUSEAllowed(t *testing.T) {
+	tcs := []struct {
+		desc  string
+		allow []string
+		deny  []string
+		inst  string
+		want  bool
+	}{
+		{desc: "no patterns", inst: "proj:region:db", want: true},
+		{desc: "allowed", allow: []string{"other:*:*", "proj:*:*"}, inst: "proj:region:db", want: true},
+		{desc: "not allowed", allow: []string{"other:*:*"}, inst: "proj:region:db", want: false},
+		{desc: "denied", deny: []string{"proj:region:*"}, inst: "proj:region:db", want: false},
+		{
+			desc:  "deny takes precedence",
+			allow: []string{"proj:*:*"},
+			deny:  []string{"*:*:db"},
+			inst:  "proj:region:db",
+			want:  false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := &Config{FUSEAllow: tc.allow, FUSEDeny: tc.deny}
+			if got := c.fuseAllowed(tc.inst); got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tcs := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{
+			desc: "network error",
+			err:  errtype.NewDialError("failed to dial", "p:r:i", errors.New("connection reset")),
+			want: true,
+		},
+		{
+			desc: "server error from the Admin API",
+			err: errtype.NewRefreshError("failed to get instance metadata", "p:r:i",
+				&googleapi.Error{Code: 503}),
+			want: true,
+		},
+		{
+			desc: "instance not found",
+			err: errtype.NewRefreshError("failed to get instance metadata", "p:r:i",
+				&googleapi.Error{Code: 404}),
+			want: false,
+		},
+		{
+			desc: "permission denied",
+			err: fmt.Errorf("wrapped: %w", errtype.NewRefreshError(
+				"failed to get instance metadata", "p:r:i", &googleapi.Error{Code: 403})),
+			want: false,
+		},
+		{
+			desc: "invalid configuration",
+			err:  errtype.NewConfigError("invalid instance connection name", "p:r:i"),
+			want: false,
+		},
+		{
+			desc: "canceled",
+			err:  context.Canceled,
+			want: false,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := isRetryable(tc.err); got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDialErrorClass(t *testing.T) {
+	tcs := []struct {
+		desc string
+		err  error
+		want string
+	}{
+		{
+			desc: "timeout",
+			err:  errtype.NewDialError("failed to dial", "p:r:i", context.DeadlineExceeded),
+			want: "timeout",
+		},
+		{
+			desc: "refresh error",
+			err: errtype.NewRefreshError("failed to get instance metadata", "p:r:i",
+				&googleapi.Error{Code: 503}),
+			want: "refresh",
+		},
+		{
+			desc: "dial error",
+			err:  errtype.NewDialError("failed to dial", "p:r:i", errors.New("connection reset")),
+			want: "dial",
+		},
+		{
+			desc: "config error",
+			err:  errtype.NewConfigError("invalid instance connection name", "p:r:i"),
+			want: "config",
+		},
+		{
+			desc: "unknown error",
+			err:  errors.New("oops"),
+			want: "other",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := dialErrorClass(tc.err); got != tc.want {
+				t.Fatalf("want = %v, got = %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRecordConnRefused(t *testing.T) {
+	if err := initMetrics(); err != nil {
+		t.Fatalf("initMetrics error: %v", err)
+	}
+	recordConnRefused("p:r:refused")
+
+	rows, err := view.RetrieveData(connRefusedView.Name)
+	if err != nil {
+		t.Fatalf("view.RetrieveData error: %v", err)
+	}
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == keyInstance && tg.Value == "p:r:refused" {
+				if got := r.Data.(*view.CountData).Value; got != 1 {
+					t.Fatalf("want 1 refused connection, got = %v", got)
+				}
+				return
+			}
+		}
+	}
+	t.Fatalf("want a row for the instance, got = %v", rows)
+}
+
+func TestBytesRecorderFlushesDeltas(t *testing.T) {
+	if err := initMetrics(); err != nil {
+		t.Fatalf("initMetrics error: %v", err)
+	}
+	inst := "p:r:bytes"
+	var sent, received atomic.Uint64
+	r := &bytesRecorder{inst: inst, sent: &sent, received: &received}
+
+	sumFor := func(direction string) float64 {
+		rows, err := view.RetrieveData(bytesView.Name)
+		if err != nil {
+			t.Fatalf("view.RetrieveData error: %v", err)
+		}
+		for _, row := range rows {
+			var instOK, dirOK bool
+			for _, tg := range row.Tags {
+				instOK = instOK || tg.Key == keyInstance && tg.Value == inst
+				dirOK = dirOK || tg.Key == keyDirection && tg.Value == direction
+			}
+			if instOK && dirOK {
+				return row.Data.(*view.SumData).Value
+			}
+		}
+		return 0
+	}
+
+	// Bytes copied by an open connection are reported before it closes.
+	sent.Add(100)
+	received.Add(1000)
+	r.flush()
+	// Only the bytes copied since the last flush are added.
+	sent.Add(10)
+	r.flush()
+	r.flush()
+
+	if got := sumFor(directionSent); got != 110 {
+		t.Fatalf("sent bytes: want = 110, got = %v", got)
+	}
+	if got := sumFor(directionReceived); got != 1000 {
+		t.Fatalf("received bytes: want = 1000, got = %v", got)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 0; attempt < 20; attempt++ {
+		max := base << attempt
+		if max > maxDialRetryBackoff || max <= 0 {
+			max = maxDialRetryBackoff
+		}
+		if got := retryBackoff(base, attempt); got <= 0 || got > max {
+			t.Fatalf("attempt %v: want backoff in (0, %v], got = %v", attempt, max, got)
+		}
+	}
+}
+
+// resultSetSize is the amount of data copied per iteration in the copy
+// benchmarks, approximating a large query result.
+const resultSetSize = 64 << 20
+
+// tcpPair returns both ends of a loopback TCP connection.
+func tcpPair(tb testing.TB) (net.Conn, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("net.Listen error: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	c1, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatalf("net.Dial error: %v", err)
+	}
+	c2 := <-accepted
+	if c2 == nil {
+		tb.Fatal("failed to accept connection")
+	}
+	return c1, c2
+}
+
+// wrappedConn embeds a net.Conn, as the instrumented connections returned by
+// the Cloud SQL Go Connector do.
+type wrappedConn struct {
+	net.Conn
+}
+
+// testTLSConfigs returns server and client TLS configurations that trust a
+// new self-signed certificate.
+func testTLSConfigs(tb testing.TB) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatalf("ecdsa.GenerateKey error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		tb.Fatalf("x509.CreateCertificate error: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		tb.Fatalf("x509.ParseCertificate error: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+	client := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	return server, client
+}
+
+// instancePair returns an instance's end of a TLS connection and the Proxy's
+// end, wrapped the way the Cloud SQL Go Connector wraps it.
+func instancePair(tb testing.TB) (net.Conn, net.Conn) {
+	serverConf, clientConf := testTLSConfigs(tb)
+	c1, c2 := tcpPair(tb)
+	instance := tls.Server(c1, serverConf)
+	proxySide := tls.Client(c2, clientConf)
+	errCh := make(chan error, 1)
+	go func() { errCh <- instance.Handshake() }()
+	if err := proxySide.Handshake(); err != nil {
+		tb.Fatalf("client handshake error: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		tb.Fatalf("server handshake error: %v", err)
+	}
+	return instance, &wrappedConn{Conn: proxySide}
+}
+
+// copyLoop is the original read/write loop used by proxyConn, which
+// allocates a new buffer for every connection.
+func copyLoop(dst, src net.Conn) error {
+	buf := make([]byte, 8*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, wErr := dst.Write(buf[:n]); wErr != nil {
+				return wErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// benchmarkCopy measures copying a large result set from a TLS connection to
+// an instance to a loopback TCP client connection with the provided copy
+// function, as proxyConn does.
+func benchmarkCopy(b *testing.B, copyFn func(dst, src net.Conn) error) {
+	b.SetBytes(resultSetSize)
+	b.ReportAllocs()
+	b.StopTimer()
+	payload := make([]byte, 1<<20)
+	for i := 0; i < b.N; i++ {
+		instance, srcProxy := instancePair(b)
+		dstProxy, client := tcpPair(b)
+		go func() {
+			for written := 0; written < resultSetSize; written += len(payload) {
+				if _, err := instance.Write(payload); err != nil {
+					break
+				}
+			}
+			_ = instance.(*tls.Conn).CloseWrite()
+		}()
+		read := make(chan int64)
+		go func() {
+			n, _ := io.Copy(io.Discard, client)
+			read <- n
+		}()
+
+		b.StartTimer()
+		err := copyFn(dstProxy, srcProxy)
+		_ = dstProxy.(*net.TCPConn).CloseWrite()
+		n := <-read
+		b.StopTimer()
+
+		if err != nil {
+			b.Fatalf("copy error: %v", err)
+		}
+		if n != resultSetSize {
+			b.Fatalf("want %v bytes, got = %v", resultSetSize, n)
+		}
+		for _, c := range []net.Conn{instance, srcProxy, dstProxy, client} {
+			_ = c.Close()
+		}
+	}
+}
+
+func BenchmarkCopy(b *testing.B) {
+	b.Run("loop", func(b *testing.B) {
+		benchmarkCopy(b, copyLoop)
+	})
+	b.Run("pooled", func(b *testing.B) {
+		benchmarkCopy(b, func(dst, src net.Conn) error {
+			return copyConn(dst, src, nil, nil, nil)
+		})
+	})
+}
+
+// captureLogger records every message logged.
+type captureLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *captureLogger) log(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Debugf(format string, args ...interface{}) { l.log(format, args...) }
+func (l *captureLogger) Infof(format string, args ...interface{})  { l.log(format, args...) }
+func (l *captureLogger) Errorf(format string, args ...interface{}) { l.log(format, args...) }
+
+// logged reports whether a message starts with prefix.
+func (l *captureLogger) logged(prefix string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.msgs {
+		if strings.HasPrefix(m, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// startProxyConn proxies a client connection to a TLS connection to an
+// instance. It returns the client's and instance's ends, the logger, and a
+// channel closed once proxyConn returns.
+func startProxyConn(t *testing.T) (net.Conn, net.Conn, *captureLogger, chan struct{}) {
+	instance, server := instancePair(t)
+	proxySide, client := tcpPair(t)
+	l := &captureLogger{}
+	c := &Client{conf: &Config{}, logger: l}
+	s := &socketMount{inst: "proj:region:inst", logger: l}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.proxyConn(s, proxySide, server)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		instance.Close()
+	})
+	return client, instance, l, done
+}
+
+func TestProxyConnHalfClosesInstance(t *testing.T) {
+	client, instance, l, done := startProxyConn(t)
+
+	if _, err := client.Write([]byte("query")); err != nil {
+		t.Fatalf("client write error: %v", err)
+	}
+	if err := client.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatalf("client CloseWrite error: %v", err)
+	}
+	// The instance sees the end of the query through the TLS wrapper, and
+	// can still respond.
+	got, err := io.ReadAll(instance)
+	if err != nil || string(got) != "query" {
+		t.Fatalf("instance read: want = query, got = %q, %v", got, err)
+	}
+	if _, err := instance.Write([]byte("result")); err != nil {
+		t.Fatalf("instance write error: %v", err)
+	}
+	instance.Close()
+	got, err = io.ReadAll(client)
+	if err != nil || string(got) != "result" {
+		t.Fatalf("client read: want = result, got = %q, %v", got, err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want proxyConn to return")
+	}
+	if !l.logged("client closed the connection") {
+		t.Fatalf("want the client to be reported as closing, got = %v", l.msgs)
+	}
+}
+
+func TestProxyConnClosesClientWhenInstanceCloses(t *testing.T) {
+	client, instance, l, done := startProxyConn(t)
+
+	instance.Close()
+	// The idle client is closed rather than left half-open.
+	if got, err := io.ReadAll(client); err != nil || len(got) != 0 {
+		t.Fatalf("client read: want EOF, got = %q, %v", got, err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want proxyConn to return")
+	}
+	if !l.logged("instance closed the connection") {
+		t.Fatalf("want the instance to be reported as closing, got = %v", l.msgs)
+	}
+}
+
+func TestCloseWriterOf(t *testing.T) {
+	_, server := instancePair(t)
+	defer server.Close()
+	cw, ok := closeWriterOf(server)
+	if !ok {
+		t.Fatal("want a closeWriter for a wrapped TLS connection")
+	}
+	if _, isTLS := cw.(*tls.Conn); !isTLS {
+		t.Fatalf("want the wrapped *tls.Conn, got = %T", cw)
+	}
+	if _, ok := closeWriterOf(&wrappedConn{}); ok {
+		t.Fatal("want no closeWriter for an empty wrapper")
+	}
+}
+
 func equalSlice[T comparable](x, y []T) bool {
 	if len(x) != len(y) {
 		return false
@@ -77,3 +630,356 @@ func equalSlice[T comparable](x, y []T) bool {
 	}
 	return true
 }
+
+// pgMsg encodes a typed Postgres message.
+func pgMsg(typ byte, body ...byte) []byte {
+	m := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(m[1:], uint32(4+len(body)))
+	return append(m, body...)
+}
+
+// pgUntyped encodes an untyped Postgres message with the provided code.
+func pgUntyped(code uint32, body ...byte) []byte {
+	m := make([]byte, 8)
+	binary.BigEndian.PutUint32(m, uint32(8+len(body)))
+	binary.BigEndian.PutUint32(m[4:], code)
+	return append(m, body...)
+}
+
+// mysqlPkt encodes a MySQL packet.
+func mysqlPkt(seq byte, payload ...byte) []byte {
+	n := len(payload)
+	return append([]byte{byte(n), byte(n >> 8), byte(n >> 16), seq}, payload...)
+}
+
+// writeBytewise writes p one byte at a time to exercise message framing.
+func writeBytewise(w io.Writer, p []byte) {
+	for i := range p {
+		_, _ = w.Write(p[i : i+1])
+	}
+}
+
+func TestPostgresSessionIdle(t *testing.T) {
+	s := newPostgresSession()
+	client, server := s.clientWriter(), s.serverWriter()
+	steps := []struct {
+		desc     string
+		client   []byte
+		server   []byte
+		wantIdle bool
+	}{
+		{
+			desc:   "SSL request refused",
+			client: pgUntyped(pgSSLRequest),
+			server: []byte{'N'},
+		},
+		{
+			desc:   "startup and authentication in progress",
+			client: pgUntyped(196608, []byte("user\x00postgres\x00\x00")...),
+			server: pgMsg('R', 0, 0, 0, 0),
+		},
+		{
+			desc:     "ready for query",
+			server:   append(pgMsg('S', []byte("a\x00b\x00")...), pgMsg('Z', 'I')...),
+			wantIdle: true,
+		},
+		{
+			desc:   "query in progress",
+			client: pgMsg('Q', []byte("BEGIN\x00")...),
+		},
+		{
+			desc:   "in a transaction",
+			server: append(pgMsg('C', []byte("BEGIN\x00")...), pgMsg('Z', 'T')...),
+		},
+		{
+			desc:   "extended query without a sync",
+			client: append(pgMsg('P', 0, 0, 0, 0), pgMsg('B', 0, 0, 0, 0, 0, 0, 0)...),
+		},
+		{
+			desc:     "transaction committed",
+			client:   append(pgMsg('S'), pgMsg('Q', []byte("COMMIT\x00")...)...),
+			server:   append(pgMsg('Z', 'T'), pgMsg('Z', 'I')...),
+			wantIdle: true,
+		},
+	}
+	for _, st := range steps {
+		writeBytewise(client, st.client)
+		writeBytewise(server, st.server)
+		if got := s.idle(); got != st.wantIdle {
+			t.Fatalf("%v: want idle = %v, got = %v", st.desc, st.wantIdle, got)
+		}
+	}
+}
+
+func TestPostgresSessionWithTLSIsNeverIdle(t *testing.T) {
+	s := newPostgresSession()
+	_, _ = s.clientWriter().Write(pgUntyped(pgSSLRequest))
+	_, _ = s.serverWriter().Write([]byte{'S'})
+	// Encrypted traffic cannot be followed.
+	_, _ = s.serverWriter().Write(pgMsg('Z', 'I'))
+	if s.idle() {
+		t.Fatal("want session with TLS to not be idle")
+	}
+}
+
+func TestMySQLSessionIdle(t *testing.T) {
+	var (
+		deprecateEOF = []byte{0x00, 0x00, 0x00, 0x01}
+		ok           = func(seq byte, status uint16) []byte {
+			return mysqlPkt(seq, 0x00, 0x00, 0x00, byte(status), byte(status>>8), 0x00, 0x00)
+		}
+		eof = func(seq byte, status uint16) []byte {
+			return mysqlPkt(seq, 0xfe, 0x00, 0x00, byte(status), byte(status>>8))
+		}
+		query = func(q string) []byte {
+			return mysqlPkt(0, append([]byte{mysqlComQuery}, q...)...)
+		}
+	)
+	tcs := []struct {
+		desc  string
+		flags []byte
+		steps []struct {
+			client, server []byte
+			wantIdle       bool
+		}
+	}{
+		{
+			desc:  "with EOF packets",
+			flags: []byte{0x00, 0x00, 0x00, 0x00},
+			steps: []struct {
+				client, server []byte
+				wantIdle       bool
+			}{
+				{server: ok(2, 0x0002), wantIdle: true},
+				{client: query("BEGIN")},
+				{server: ok(1, mysqlStatusInTrans)},
+				{client: query("SELECT 1")},
+				{server: append(append(mysqlPkt(1, 0x01), mysqlPkt(2, 0x03, 'd', 'e', 'f')...), eof(3, mysqlStatusInTrans)...)},
+				{server: append(mysqlPkt(4, 0x01, '1'), eof(5, mysqlStatusInTrans)...)},
+				{client: query("COMMIT")},
+				{server: ok(1, 0x0002), wantIdle: true},
+				{client: query("SELECT 1")},
+				{server: append(append(mysqlPkt(1, 0x01), mysqlPkt(2, 0x03, 'd', 'e', 'f')...), eof(3, 0x0002)...)},
+				{server: mysqlPkt(4, 0x01, '1')},
+				{server: eof(5, 0x0002), wantIdle: true},
+			},
+		},
+		{
+			desc:  "with deprecated EOF packets",
+			flags: deprecateEOF,
+			steps: []struct {
+				client, server []byte
+				wantIdle       bool
+			}{
+				{server: ok(2, 0x0002), wantIdle: true},
+				{client: query("SELECT 1")},
+				{server: append(mysqlPkt(1, 0x01), mysqlPkt(2, 0x03, 'd', 'e', 'f')...)},
+				{server: mysqlPkt(3, 0x01, '1')},
+				{server: mysqlPkt(4, 0xfe, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00), wantIdle: true},
+				// A prepared statement response is not followed.
+				{client: mysqlPkt(0, 0x16, 'S')},
+				{server: ok(1, 0x0002)},
+				{client: query("DO 1")},
+				{server: ok(1, 0x0002), wantIdle: true},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := newMySQLSession()
+			client, server := s.clientWriter(), s.serverWriter()
+			writeBytewise(server, mysqlPkt(0, 0x0a, '8', '.', '0', 0x00))
+			writeBytewise(client, mysqlPkt(1, append(tc.flags, make([]byte, 40)...)...))
+			for i, st := range tc.steps {
+				writeBytewise(client, st.client)
+				writeBytewise(server, st.server)
+				if got := s.idle(); got != st.wantIdle {
+					t.Fatalf("step %v: want idle = %v, got = %v", i, st.wantIdle, got)
+				}
+			}
+		})
+	}
+}
+
+// versionDialer counts engine version lookups.
+type versionDialer struct {
+	cloudsql.Dialer
+	lookups atomic.Int32
+}
+
+func (d *versionDialer) EngineVersion(context.Context, string) (string, error) {
+	d.lookups.Add(1)
+	return "POSTGRES_16", nil
+}
+
+func TestNewSessionTrackerResolvesEngineOnce(t *testing.T) {
+	d := &versionDialer{}
+	c := &Client{conf: &Config{TransactionAwareShutdown: true}, dialer: d}
+	s := &socketMount{
+		inst:         "proj:region:pg",
+		cfg:          InstanceConnConfig{Name: "proj:region:pg"},
+		dialSettings: dialSettings{timeout: time.Second},
+	}
+	for i := 0; i < 3; i++ {
+		if c.newSessionTracker(s) == nil {
+			t.Fatal("want a session tracker for Postgres, got = nil")
+		}
+	}
+	if got := d.lookups.Load(); got != 1 {
+		t.Fatalf("engine version lookups, want = 1, got = %v", got)
+	}
+}
+
+// flakyListener fails the first call to Accept.
+type flakyListener struct {
+	net.Listener
+	failed atomic.Bool
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failed.CompareAndSwap(false, true) {
+		return nil, errors.New("accept failed")
+	}
+	return l.Listener.Accept()
+}
+
+func TestServeWithRestart(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewStdLogger(io.Discard, io.Discard)
+	c := &Client{logger: logger, conf: &Config{}}
+	s := &socketMount{
+		inst:     "proj:region:inst",
+		listener: &flakyListener{Listener: ln},
+		logger:   logger,
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.serveWithRestart(context.Background(), s) }()
+
+	// Wait for the listener to be restarted.
+	var restarted bool
+	for i := 0; i < 50; i++ {
+		s.listenerMu.Lock()
+		_, flaky := s.listener.(*flakyListener)
+		s.listenerMu.Unlock()
+		if !flaky && s.serving.Load() && !s.restarting.Load() {
+			restarted = true
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !restarted {
+		t.Fatal("listener was not restarted")
+	}
+	if got, want := s.Addr().String(), ln.Addr().String(); got != want {
+		t.Fatalf("restarted listener address: want = %v, got = %v", want, got)
+	}
+
+	// Closing the mount stops serving and reports the error.
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("want non-nil error after close, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("serveWithRestart did not return after close")
+	}
+}
+
+func TestEngineCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engines.json")
+	l := log.NewStdLogger(io.Discard, io.Discard)
+
+	c := loadEngineCache(path, l)
+	if _, ok := c.get("proj:region:inst"); ok {
+		t.Fatal("want empty cache for a missing file")
+	}
+	c.put("proj:region:inst", "POSTGRES_14")
+
+	got, ok := loadEngineCache(path, l).get("proj:region:inst")
+	if !ok || got != "POSTGRES_14" {
+		t.Fatalf("want = POSTGRES_14, got = %q (ok = %v)", got, ok)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loadEngineCache(path, l).get("proj:region:inst"); ok {
+		t.Fatal("want empty cache for a corrupt file")
+	}
+
+	var nilCache *engineCache
+	nilCache.put("proj:region:inst", "MYSQL_8_0")
+	if _, ok := nilCache.get("proj:region:inst"); ok {
+		t.Fatal("want nil cache to be empty")
+	}
+}
+
+func TestUnixSocketName(t *testing.T) {
+	tcs := []struct {
+		desc    string
+		tmpl    string
+		inst    string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "no template",
+			inst: "proj:region:inst",
+			want: "proj:region:inst",
+		},
+		{
+			desc: "project and instance",
+			tmpl: "{project}-{instance}",
+			inst: "proj:region:inst",
+			want: "proj-inst",
+		},
+		{
+			desc: "all placeholders",
+			tmpl: "{region}.{instance}.{name}",
+			inst: "proj:region:inst",
+			want: "region.inst.proj:region:inst",
+		},
+		{
+			desc: "domain-scoped project",
+			tmpl: "{project}-{instance}",
+			inst: "example.com:proj:region:inst",
+			want: "example.com:proj-inst",
+		},
+		{
+			desc:    "unknown placeholder",
+			tmpl:    "{zone}-{instance}",
+			inst:    "proj:region:inst",
+			wantErr: true,
+		},
+		{
+			desc:    "path separator",
+			tmpl:    "{project}/{instance}",
+			inst:    "proj:region:inst",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := UnixSocketName(tc.tmpl, tc.inst)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got = %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnixSocketName error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("want = %q, got = %q", tc.want, got)
+			}
+		})
+	}
+}
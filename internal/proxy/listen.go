@@ -0,0 +1,127 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+)
+
+// listenRetryBackoff is the initial delay before restarting a failed
+// listener.
+const listenRetryBackoff = time.Second
+
+// listen creates a listener on the address. A stale Unix socket at the
+// address is removed first, and the new socket is given the mode and
+// ownership in perms.
+func listen(ctx context.Context, l cloudsql.Logger, network, address string, perms unixSocketPerms) (net.Listener, error) {
+	if network == "unix" {
+		if err := removeStaleUnixSocket(address, l); err != nil {
+			return nil, err
+		}
+	}
+	lc := net.ListenConfig{KeepAlive: 30 * time.Second}
+	ln, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := perms.apply(address); err != nil {
+			_ = ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+// errMountClosed is returned when restarting the listener of a mount that
+// has been closed.
+var errMountClosed = errors.New("socket mount is closed")
+
+// serveWithRestart serves the socket mount until it is closed or removed.
+// When the listener fails, the failure is logged, the mount reports as
+// unhealthy, and the listener is restarted with backoff, so that other
+// mounts keep serving. It returns nil if the mount was removed, and the
+// listener error otherwise.
+func (c *Client) serveWithRestart(ctx context.Context, s *socketMount) error {
+	attempt := 0
+	for {
+		start := time.Now()
+		err := c.serveSocketMount(ctx, s)
+		if s.removed.Load() {
+			return nil
+		}
+		// A listener passed by socket activation cannot be opened again.
+		if s.isClosed() || s.activated || ctx.Err() != nil {
+			return err
+		}
+		if time.Since(start) > maxDialRetryBackoff {
+			// The listener served for a while, so start the backoff over.
+			attempt = 0
+		}
+		s.acceptFailures.fail()
+		s.restarting.Store(true)
+		s.logger.Errorf("Listener failed, restarting: %v", err)
+		for {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(retryBackoff(listenRetryBackoff, attempt)):
+			}
+			attempt++
+			rErr := s.relisten(ctx)
+			if errors.Is(rErr, errMountClosed) {
+				return err
+			}
+			if rErr == nil {
+				break
+			}
+			s.acceptFailures.fail()
+			s.logger.Errorf("Failed to restart listener: %v", rErr)
+		}
+		s.restarting.Store(false)
+		s.acceptFailures.succeed()
+		s.logger.Infof("Listening on %s", s.Addr())
+	}
+}
+
+// isClosed reports whether the mount has been closed.
+func (s *socketMount) isClosed() bool {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	return s.closed
+}
+
+// relisten replaces the mount's listener with a new one on the same address.
+func (s *socketMount) relisten(ctx context.Context) error {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	if s.closed {
+		return errMountClosed
+	}
+	addr := s.listener.Addr()
+	// Closing the old listener frees the address and removes a Unix socket.
+	_ = s.listener.Close()
+	ln, err := listen(ctx, s.logger, addr.Network(), addr.String(), s.perms)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	return nil
+}
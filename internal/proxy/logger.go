@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+)
+
+// Log field keys attached to messages about instances and connections.
+const (
+	logKeyInstance   = "instance"
+	logKeyConnID     = "conn_id"
+	logKeyClientAddr = "client_address"
+)
+
+// withFields returns a logger that adds the key-value pair fields to every
+// message. Loggers that do not support fields have them appended to the
+// message instead.
+func withFields(l cloudsql.Logger, args ...any) cloudsql.Logger {
+	if sl, ok := l.(cloudsql.StructuredLogger); ok {
+		return sl.With(args...)
+	}
+	var b strings.Builder
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	if fl, ok := l.(*fieldLogger); ok {
+		return &fieldLogger{l: fl.l, fields: fl.fields + b.String()}
+	}
+	return &fieldLogger{l: l, fields: b.String()}
+}
+
+// fieldLogger appends fields to the messages of a Logger that does not
+// support them.
+type fieldLogger struct {
+	l      cloudsql.Logger
+	fields string
+}
+
+func (f *fieldLogger) Debugf(format string, args ...interface{}) {
+	f.l.Debugf("%s%s", fmt.Sprintf(format, args...), f.fields)
+}
+
+func (f *fieldLogger) Infof(format string, args ...interface{}) {
+	f.l.Infof("%s%s", fmt.Sprintf(format, args...), f.fields)
+}
+
+func (f *fieldLogger) Errorf(format string, args ...interface{}) {
+	f.l.Errorf("%s%s", fmt.Sprintf(format, args...), f.fields)
+}
@@ -0,0 +1,316 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/cloudsqlconn/errtype"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Reasons a proxied connection was closed.
+const (
+	closeReasonClient      = "client_closed"
+	closeReasonInstance    = "instance_closed"
+	closeReasonError       = "error"
+	closeReasonIdleTimeout = "idle_timeout"
+	closeReasonMaxLifetime = "max_lifetime"
+	closeReasonAdmin       = "admin_closed"
+	closeReasonShutdown    = "shutdown_idle"
+)
+
+// Directions of proxied traffic.
+const (
+	// directionSent is traffic from the client to the instance.
+	directionSent = "sent"
+	// directionReceived is traffic from the instance to the client.
+	directionReceived = "received"
+)
+
+var (
+	keyInstance, _    = tag.NewKey("cloudsql_instance")
+	keyCloseReason, _ = tag.NewKey("close_reason")
+	keyErrorClass, _  = tag.NewKey("error_class")
+	keyDirection, _   = tag.NewKey("direction")
+
+	mOpenConns = stats.Int64(
+		"cloudsqlproxy/connection",
+		"The number of open proxied connections",
+		stats.UnitDimensionless,
+	)
+	mConnAccepted = stats.Int64(
+		"cloudsqlproxy/connection_accepted",
+		"A client connection was accepted",
+		stats.UnitDimensionless,
+	)
+	mConnRefused = stats.Int64(
+		"cloudsqlproxy/connection_refused",
+		"A client connection was refused because of a connection limit",
+		stats.UnitDimensionless,
+	)
+	mConnClosed = stats.Int64(
+		"cloudsqlproxy/connection_closed",
+		"A proxied connection was closed",
+		stats.UnitDimensionless,
+	)
+	mDialLatencyMS = stats.Int64(
+		"cloudsqlproxy/latency",
+		"The latency in milliseconds to connect to an instance, including retries",
+		stats.UnitMilliseconds,
+	)
+	mDialError = stats.Int64(
+		"cloudsqlproxy/dial_failure",
+		"A failed attempt to connect to an instance",
+		stats.UnitDimensionless,
+	)
+	mBytes = stats.Int64(
+		"cloudsqlproxy/bytes",
+		"The bytes copied for a proxied connection",
+		stats.UnitBytes,
+	)
+	mConnDuration = stats.Float64(
+		"cloudsqlproxy/duration",
+		"How long a proxied connection was open",
+		stats.UnitSeconds,
+	)
+	mFUSESockets = stats.Int64(
+		"cloudsqlproxy/fuse_socket",
+		"The number of sockets created in FUSE mode",
+		stats.UnitDimensionless,
+	)
+
+	openConnsView = &view.View{
+		Name:        "cloudsqlproxy/open_connections",
+		Measure:     mOpenConns,
+		Description: "The current number of open proxied connections",
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{keyInstance},
+	}
+	connAcceptedView = &view.View{
+		Name:        "cloudsqlproxy/accepted_connection_count",
+		Measure:     mConnAccepted,
+		Description: "The number of accepted client connections",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyInstance},
+	}
+	connRefusedView = &view.View{
+		Name:        "cloudsqlproxy/refused_connection_count",
+		Measure:     mConnRefused,
+		Description: "The number of client connections refused because of a connection limit",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyInstance},
+	}
+	connClosedView = &view.View{
+		Name:        "cloudsqlproxy/closed_connection_count",
+		Measure:     mConnClosed,
+		Description: "The number of closed proxied connections by reason",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyInstance, keyCloseReason},
+	}
+	dialLatencyView = &view.View{
+		Name:        "cloudsqlproxy/dial_latency",
+		Measure:     mDialLatencyMS,
+		Description: "The distribution of latencies to connect to an instance (ms)",
+		// Latency in buckets, e.g., >=0ms, >=100ms, etc.
+		Aggregation: view.Distribution(0, 5, 25, 100, 250, 500, 1000, 2000, 5000, 30000),
+		TagKeys:     []tag.Key{keyInstance},
+	}
+	dialErrorView = &view.View{
+		Name:        "cloudsqlproxy/dial_failure_count",
+		Measure:     mDialError,
+		Description: "The number of failed attempts to connect to an instance by error class",
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{keyInstance, keyErrorClass},
+	}
+	bytesView = &view.View{
+		Name:        "cloudsqlproxy/bytes_transferred",
+		Measure:     mBytes,
+		Description: "The number of bytes copied for proxied connections by direction",
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{keyInstance, keyDirection},
+	}
+	connDurationView = &view.View{
+		Name:        "cloudsqlproxy/connection_duration",
+		Measure:     mConnDuration,
+		Description: "The distribution of proxied connection durations (s)",
+		// Durations in buckets, e.g., >=0s, >=1s, etc.
+		Aggregation: view.Distribution(0, 1, 10, 60, 300, 900, 3600, 14400, 86400),
+		TagKeys:     []tag.Key{keyInstance, keyCloseReason},
+	}
+	fuseSocketsView = &view.View{
+		Name:        "cloudsqlproxy/fuse_sockets",
+		Measure:     mFUSESockets,
+		Description: "The current number of live sockets created in FUSE mode",
+		Aggregation: view.LastValue(),
+	}
+
+	registerOnce sync.Once
+	registerErr  error
+)
+
+// initMetrics registers all views once. Without registering views, metrics
+// will not be reported.
+func initMetrics() error {
+	registerOnce.Do(func() {
+		if err := view.Register(
+			openConnsView,
+			connAcceptedView,
+			connRefusedView,
+			connClosedView,
+			dialLatencyView,
+			dialErrorView,
+			bytesView,
+			connDurationView,
+			fuseSocketsView,
+		); err != nil {
+			registerErr = fmt.Errorf("failed to initialize metrics: %w", err)
+		}
+	})
+	return registerErr
+}
+
+// instanceContext returns a context tagged with the instance.
+func instanceContext(inst string) context.Context {
+	// tag.New errors only if a tag is duplicated, which cannot happen with a
+	// fresh context.
+	ctx, _ := tag.New(context.Background(), tag.Upsert(keyInstance, inst))
+	return ctx
+}
+
+// recordOpenConnections reports the number of open connections to an
+// instance.
+func recordOpenConnections(inst string, n uint64) {
+	stats.Record(instanceContext(inst), mOpenConns.M(int64(n)))
+}
+
+// recordConnAccepted reports that a client connection was accepted.
+func recordConnAccepted(inst string) {
+	stats.Record(instanceContext(inst), mConnAccepted.M(1))
+}
+
+// recordConnRefused reports that a client connection was refused.
+func recordConnRefused(inst string) {
+	stats.Record(instanceContext(inst), mConnRefused.M(1))
+}
+
+// recordDialLatency reports how long a successful connection to an instance
+// took.
+func recordDialLatency(inst string, d time.Duration) {
+	stats.Record(instanceContext(inst), mDialLatencyMS.M(d.Milliseconds()))
+}
+
+// recordDialError reports a failed attempt to connect to an instance.
+func recordDialError(inst string, err error) {
+	ctx, _ := tag.New(instanceContext(inst), tag.Upsert(keyErrorClass, dialErrorClass(err)))
+	stats.Record(ctx, mDialError.M(1))
+}
+
+// recordConnClosed reports that a proxied connection was closed and why,
+// along with how long it was open.
+func recordConnClosed(inst, reason string, d time.Duration) {
+	ctx, _ := tag.New(instanceContext(inst), tag.Upsert(keyCloseReason, reason))
+	stats.Record(ctx, mConnClosed.M(1), mConnDuration.M(d.Seconds()))
+}
+
+// recordBytes reports bytes copied for a proxied connection by direction.
+func recordBytes(inst string, sent, received uint64) {
+	if sent > 0 {
+		ctx, _ := tag.New(instanceContext(inst), tag.Upsert(keyDirection, directionSent))
+		stats.Record(ctx, mBytes.M(int64(sent)))
+	}
+	if received > 0 {
+		ctx, _ := tag.New(instanceContext(inst), tag.Upsert(keyDirection, directionReceived))
+		stats.Record(ctx, mBytes.M(int64(received)))
+	}
+}
+
+// bytesFlushInterval is how often the bytes copied for an open connection
+// are reported.
+const bytesFlushInterval = 10 * time.Second
+
+// bytesRecorder reports the bytes copied for a connection while it is open,
+// so that long-lived connections show up in the metrics before they close.
+type bytesRecorder struct {
+	inst           string
+	sent, received *atomic.Uint64
+
+	// mu guards the bytes already reported.
+	mu                     sync.Mutex
+	lastSent, lastReceived uint64
+}
+
+// run reports the bytes copied every bytesFlushInterval until done is
+// closed.
+func (r *bytesRecorder) run(done <-chan struct{}) {
+	t := time.NewTicker(bytesFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			r.flush()
+		}
+	}
+}
+
+// flush reports the bytes copied since the last flush.
+func (r *bytesRecorder) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent, received := r.sent.Load(), r.received.Load()
+	recordBytes(r.inst, sent-r.lastSent, received-r.lastReceived)
+	r.lastSent, r.lastReceived = sent, received
+}
+
+// recordFUSESockets reports the number of live sockets created in FUSE mode.
+func recordFUSESockets(n int) {
+	stats.Record(context.Background(), mFUSESockets.M(int64(n)))
+}
+
+// dialErrorClass groups dial errors into a small set of classes suitable for
+// use as a metric tag.
+func dialErrorClass(err error) string {
+	var (
+		cfgErr     *errtype.ConfigError
+		reErr      *errtype.ResourceExhaustedError
+		refreshErr *errtype.RefreshError
+		dialErr    *errtype.DialError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &cfgErr):
+		return "config"
+	case errors.As(err, &reErr):
+		return "resource_exhausted"
+	case errors.As(err, &refreshErr):
+		return "refresh"
+	case errors.As(err, &dialErr):
+		return "dial"
+	default:
+		return "other"
+	}
+}
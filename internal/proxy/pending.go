@@ -0,0 +1,176 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"time"
+)
+
+// States of an instance reported by Client.Instances.
+const (
+	// InstanceMounted is the state of an instance with a listening socket.
+	InstanceMounted = "mounted"
+	// InstancePending is the state of an instance that could not be mounted
+	// at startup and is retried in the background.
+	InstancePending = "pending"
+	// InstanceFailed is the state of an instance that could not be mounted
+	// with an error that cannot succeed on a retry.
+	InstanceFailed = "failed"
+)
+
+// pendingRetryBackoff is the initial delay between attempts to mount an
+// instance that was skipped at startup.
+const pendingRetryBackoff = time.Second
+
+// pendingInstance is an instance that was skipped at startup because its
+// socket could not be mounted.
+type pendingInstance struct {
+	cfg      InstanceConnConfig
+	attempts int
+	err      error
+	// failed is set once err cannot succeed on a retry.
+	failed bool
+}
+
+// newPendingInstance records a failed attempt to mount the instance.
+func newPendingInstance(inst InstanceConnConfig, err error) *pendingInstance {
+	return &pendingInstance{cfg: inst, attempts: 1, err: err, failed: !isRetryable(err)}
+}
+
+// state returns the state of the pending instance.
+func (p *pendingInstance) state() string {
+	if p.failed {
+		return InstanceFailed
+	}
+	return InstancePending
+}
+
+// retryPending mounts the instances skipped at startup, retrying with
+// exponential backoff until every instance is mounted, has failed with an
+// error that cannot succeed on a retry, the Client is closed, or ctx is
+// done.
+func (c *Client) retryPending(ctx context.Context) {
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryBackoff(pendingRetryBackoff, attempt)):
+		}
+		if c.mountPending(ctx) == 0 {
+			return
+		}
+	}
+}
+
+// mountPending attempts to mount every pending instance once and serves the
+// instances that are mounted. It returns the number of instances that are
+// still pending.
+func (c *Client) mountPending(ctx context.Context) int {
+	c.mntsMu.Lock()
+	var retry []*pendingInstance
+	for _, p := range c.pending {
+		if !p.failed {
+			retry = append(retry, p)
+		}
+	}
+	c.mntsMu.Unlock()
+
+	for _, p := range retry {
+		// Mount without holding mountMu or mntsMu, as the Admin API may be
+		// slow to respond.
+		mctx, cancel := context.WithTimeout(ctx, defaultDialTimeout)
+		m, err := c.newSocketMount(mctx, c.conf, c.pc, p.cfg)
+		cancel()
+		c.addPending(p, m, err)
+	}
+
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	if c.checkMountable() != nil {
+		// Stop retrying once the Client no longer mounts instances.
+		return 0
+	}
+	var n int
+	for _, p := range c.pending {
+		if !p.failed {
+			n++
+		}
+	}
+	return n
+}
+
+// addPending records the result of an attempt to mount the pending
+// instance, and serves the instance once it is mounted.
+func (c *Client) addPending(p *pendingInstance, m *socketMount, err error) {
+	c.mountMu.Lock()
+	defer c.mountMu.Unlock()
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	if !c.isPending(p) || c.checkMountable() != nil {
+		// The instance was removed or added while it was being mounted, or
+		// the Client no longer mounts instances.
+		if m != nil {
+			m.Close()
+		}
+		return
+	}
+	p.attempts++
+	if err != nil {
+		l := withFields(c.logger, logKeyInstance, p.cfg.Name)
+		p.err = err
+		switch {
+		case !isRetryable(err):
+			p.failed = true
+			l.Errorf("Unable to mount socket after %d attempts, not retrying: %v", p.attempts, err)
+		case p.attempts&(p.attempts-1) == 0:
+			// Report failures as the backoff grows, so that an instance that
+			// never mounts is not silent at the default log level.
+			l.Errorf("Unable to mount socket after %d attempts, retrying: %v", p.attempts, err)
+		default:
+			l.Debugf("Unable to mount socket (attempt %d), retrying: %v", p.attempts, err)
+		}
+		return
+	}
+	c.removePending(p.cfg.Name)
+	m.logger.Infof("Listening on %s after %d attempts", m.Addr(), p.attempts)
+	c.mnts = append(c.mnts, m)
+	if c.serveCtx != nil {
+		c.serve(c.serveCtx, m, c.exitCh)
+	}
+}
+
+// isPending reports whether p is still waiting to be mounted. The caller must
+// hold mntsMu.
+func (c *Client) isPending(p *pendingInstance) bool {
+	for _, q := range c.pending {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
+
+// removePending stops retrying the named instance and reports whether it
+// was pending. The caller must hold mntsMu.
+func (c *Client) removePending(name string) bool {
+	for i, p := range c.pending {
+		if p.cfg.Name == name {
+			c.pending = append(c.pending[:i:i], c.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
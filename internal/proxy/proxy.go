@@ -16,11 +16,13 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path"
+	"reflect"
 	"regexp"
 	"strings"
 	"sync"
@@ -95,6 +97,15 @@ type InstanceConnConfig struct {
 	// necessary. If set, UnixSocketPath takes precedence over UnixSocket, Addr
 	// and Port.
 	UnixSocketPath string
+	// UnixSocketPort, if set, creates the Unix socket in the UnixSocket
+	// directory with the name clients derive from a port: `.s.PGSQL.<port>`
+	// for Postgres and `.s.MYSQL.<port>` for MySQL. It is not used with
+	// UnixSocketPath.
+	UnixSocketPort int
+	// Engine is the database engine of the instance, one of "POSTGRES",
+	// "MYSQL", or "SQLSERVER". If set, the engine version is not looked up
+	// to choose a default port or the Postgres Unix socket layout.
+	Engine string
 	// SQLDataEnabled enables connections through the SqlDataService for this connection.
 	SQLDataEnabled *bool
 	// IAMAuthN enables automatic IAM DB Authentication for the instance.
@@ -108,6 +119,43 @@ type InstanceConnConfig struct {
 	// PSC tells the proxy to attempt to connect to the db instance's
 	// private service connect endpoint
 	PSC *bool
+
+	// MaxConnections is the maximum number of connections to this instance.
+	// Connections beyond the limit are refused, or queued if a queue timeout is
+	// set. A zero-value indicates no limit.
+	MaxConnections uint64
+
+	// ConnQueueTimeout is how long a connection waits for a free slot when a
+	// connection limit is reached. If it is nil, the global setting applies.
+	ConnQueueTimeout *time.Duration
+
+	// DialTimeout is the time allowed for each attempt to connect to the
+	// instance. If it is nil, the global setting applies.
+	DialTimeout *time.Duration
+	// DialRetries is the number of times a failed dial is retried. If it is
+	// nil, the global setting applies.
+	DialRetries *int
+	// DialRetryBackoff is the initial delay between dial attempts. If it is
+	// nil, the global setting applies.
+	DialRetryBackoff *time.Duration
+
+	// IdleTimeout closes connections that have seen no traffic in either
+	// direction for this long. If it is nil, the global setting applies.
+	IdleTimeout *time.Duration
+	// MaxConnLifetime closes connections once they have been open for this
+	// long. If it is nil, the global setting applies.
+	MaxConnLifetime *time.Duration
+}
+
+// FUSEInstanceRule applies instance settings to the sockets created in FUSE
+// mode for instance connection names that match a pattern.
+type FUSEInstanceRule struct {
+	// Pattern is matched against the instance connection name using the
+	// syntax of path.Match, e.g., "my-project:*:*".
+	Pattern string
+	// Settings holds the settings for matching instances. The Name, Addr,
+	// Port, UnixSocket, and UnixSocketPath fields are ignored.
+	Settings InstanceConnConfig
 }
 
 // Config contains all the configuration provided by the caller.
@@ -115,6 +163,9 @@ type Config struct {
 	// Filepath is the path to a configuration file.
 	Filepath string
 
+	// WatchConfigFile reloads the configuration file whenever it changes.
+	WatchConfigFile bool
+
 	// UserAgent is the user agent to use when connecting to the cloudsql instance
 	UserAgent string
 
@@ -154,6 +205,37 @@ type Config struct {
 	// connected to any Instances. If set, takes precedence over Addr and Port.
 	UnixSocket string
 
+	// UnixSocketMode is the file mode of Unix sockets. If zero, sockets allow
+	// access for user, group, and other.
+	UnixSocketMode os.FileMode
+
+	// UnixSocketUID and UnixSocketGID are the owner and group of Unix
+	// sockets. If nil, the owner or group is left unchanged.
+	UnixSocketUID *int
+	UnixSocketGID *int
+
+	// UnixSocketNameTemplate names the Unix sockets created in a directory,
+	// where {project}, {region}, and {instance} are replaced with the parts
+	// of the instance connection name and {name} with the whole name. If
+	// empty, sockets are named with the instance connection name.
+	UnixSocketNameTemplate string
+
+	// UnixSocketLock locks the UnixSocket directory with a lock file holding
+	// the PID of the proxy, so that a second proxy cannot use the directory.
+	UnixSocketLock bool
+
+	// Listeners are pre-opened listeners, e.g., from systemd socket
+	// activation, keyed by instance connection name. An instance with a
+	// listener uses it in place of its address, port, or Unix socket.
+	Listeners map[string]net.Listener
+
+	// InheritedListeners are listeners created by the Proxy process being
+	// upgraded, keyed by instance connection name. They are used like
+	// Listeners, but are owned by the Proxy: a Unix socket is removed when
+	// the listener is closed, and a failed listener is opened again.
+	// Listeners no configured instance uses are closed.
+	InheritedListeners map[string]net.Listener
+
 	// FUSEDir enables a file system in user space at the provided path that
 	// connects to the requested instance only when a client requests it.
 	FUSEDir string
@@ -163,6 +245,26 @@ type Config struct {
 	// is not accessed directly.
 	FUSETempDir string
 
+	// FUSEInstanceRules apply per-instance settings to the sockets created
+	// in FUSE mode. The first rule whose pattern matches the instance
+	// connection name applies. Instances that match no rule use the global
+	// settings only.
+	FUSEInstanceRules []FUSEInstanceRule
+
+	// FUSEAllow restricts FUSE lookups to instance connection names that
+	// match one of the patterns, using the syntax of path.Match. If empty,
+	// all instances are allowed.
+	FUSEAllow []string
+
+	// FUSEDeny rejects FUSE lookups of instance connection names that match
+	// one of the patterns. FUSEDeny takes precedence over FUSEAllow.
+	FUSEDeny []string
+
+	// FUSEIdleTimeout closes and removes a socket created in FUSE mode once
+	// it has had no connections for this long. A zero value keeps sockets
+	// until shutdown.
+	FUSEIdleTimeout time.Duration
+
 	// IAMAuthN enables automatic IAM DB Authentication for all instances.
 	// MySQL and Postgres only.
 	IAMAuthN bool
@@ -172,6 +274,33 @@ type Config struct {
 	// connections. A zero-value indicates no limit.
 	MaxConnections uint64
 
+	// ConnQueueTimeout sets how long a connection waits for a free slot when
+	// a connection limit is reached before it is refused. A zero-value means
+	// connections are refused immediately.
+	ConnQueueTimeout time.Duration
+
+	// DialTimeout sets the time allowed for each attempt to connect to an
+	// instance. A zero-value means 30 seconds.
+	DialTimeout time.Duration
+
+	// DialRetries sets the number of times a failed dial is retried before the
+	// client connection is closed. Errors that cannot succeed on a retry, e.g.,
+	// authorization errors, are never retried.
+	DialRetries int
+
+	// DialRetryBackoff sets the initial delay between dial attempts. The delay
+	// doubles with each attempt and is randomized with jitter. A zero-value
+	// means 500 milliseconds.
+	DialRetryBackoff time.Duration
+
+	// IdleTimeout sets how long a connection may go without traffic in either
+	// direction before it is closed. A zero-value means no idle timeout.
+	IdleTimeout time.Duration
+
+	// MaxConnLifetime sets how long a connection may stay open before it is
+	// closed, regardless of activity. A zero-value means no maximum lifetime.
+	MaxConnLifetime time.Duration
+
 	// WaitBeforeClose sets the duration to wait after receiving a shutdown signal
 	// but before closing the process. Not setting this field means to initiate
 	// the shutdown process immediately.
@@ -182,6 +311,12 @@ type Config struct {
 	// regardless of any open connections.
 	WaitOnClose time.Duration
 
+	// TransactionAwareShutdown follows the Postgres and MySQL protocols of
+	// each connection so that, during shutdown, connections that are idle
+	// outside of a transaction are closed right away while connections in a
+	// transaction get the full WaitOnClose period.
+	TransactionAwareShutdown bool
+
 	// PrivateIP enables connections via the database server's private IP address
 	// for all instances.
 	PrivateIP bool
@@ -230,6 +365,12 @@ type Config struct {
 	StructuredLogs bool
 	// Quiet controls whether only error messages are logged.
 	Quiet bool
+	// LogLevel is the minimum level of logged messages: debug, info, warn,
+	// or error. It takes precedence over DebugLogs and Quiet.
+	LogLevel string
+	// LogFormat is the format of logged messages: text, json, or logfmt. It
+	// takes precedence over StructuredLogs.
+	LogFormat string
 
 	// TelemetryProject enables sending metrics and traces to the specified project.
 	TelemetryProject string
@@ -240,11 +381,23 @@ type Config struct {
 	TelemetryTracingSampleRate int
 	// ExitZeroOnSigterm exits with 0 exit code when Sigterm received
 	ExitZeroOnSigterm bool
-	// DisableTraces disables tracing when TelemetryProject is set.
+	// DisableTraces disables tracing when TelemetryProject or OTLPEndpoint is
+	// set.
 	DisableTraces bool
-	// DisableMetrics disables metrics when TelemetryProject is set.
+	// DisableMetrics disables metrics when TelemetryProject or OTLPEndpoint
+	// is set.
 	DisableMetrics bool
 
+	// OTLPEndpoint enables sending metrics and traces to an OpenTelemetry
+	// collector at the provided URL.
+	OTLPEndpoint string
+	// OTLPHeaders sets additional headers sent with every OTLP export, e.g.,
+	// for authentication.
+	OTLPHeaders map[string]string
+	// OTLPProtocol sets the protocol used to export to the OTLP endpoint,
+	// either "grpc" or "http".
+	OTLPProtocol string
+
 	// Prometheus enables a Prometheus endpoint served at the address and
 	// port specified by HTTPAddress and HTTPPort.
 	Prometheus bool
@@ -255,6 +408,34 @@ type Config struct {
 	// specified by HTTPAddress and HTTPPort.
 	HealthCheck bool
 
+	// GRPCHealthPort enables a gRPC health check server on this port of
+	// HTTPAddress. It reports the same checks as the health check server.
+	GRPCHealthPort string
+
+	// ReadinessCheckInterval enables dialing every instance on this interval
+	// in the background, with the results reported by the readiness check.
+	// Zero disables the checks.
+	ReadinessCheckInterval time.Duration
+	// ReadinessCheckTimeout limits how long each readiness dial may take.
+	ReadinessCheckTimeout time.Duration
+	// ReadinessFailureThreshold is the number of consecutive failed dials
+	// before an instance is reported as unreachable.
+	ReadinessFailureThreshold int
+	// ReadinessSuccessThreshold is the number of consecutive successful dials
+	// before an unreachable instance is reported as reachable again.
+	ReadinessSuccessThreshold int
+
+	// LivenessRequireServing fails the liveness check when no mounted socket
+	// is accepting connections.
+	LivenessRequireServing bool
+	// LivenessMaxDialFailure fails the liveness check once every dial has
+	// failed for this long. Zero disables the condition.
+	LivenessMaxDialFailure time.Duration
+	// LivenessMaxAcceptFailure fails the liveness check once accepting
+	// connections on a socket has failed for this long. Zero disables the
+	// condition.
+	LivenessMaxAcceptFailure time.Duration
+
 	// HTTPAddress sets the address for the health check and prometheus server.
 	HTTPAddress string
 	// HTTPPort sets the port for the health check and prometheus server.
@@ -267,6 +448,21 @@ type Config struct {
 	// QuitQuitQuit enables a handler that will shut the Proxy down upon
 	// receiving a GET or POST request.
 	QuitQuitQuit bool
+	// InstancesAPI enables a handler that adds and removes instances while
+	// the Proxy is running.
+	InstancesAPI bool
+	// ConnectionsAPI enables handlers that list active connections and close
+	// them by ID.
+	ConnectionsAPI bool
+	// DrainAPI enables a handler that fails the readiness check and
+	// optionally stops accepting connections ahead of shutdown.
+	DrainAPI bool
+	// UpgradeAPI enables a handler that hands the listeners to a new copy of
+	// the Proxy and shuts down once the open connections finish.
+	UpgradeAPI bool
+	// UpgradeOnSIGUSR2 hands the listeners to a new copy of the Proxy when a
+	// SIGUSR2 signal is received, as UpgradeAPI does.
+	UpgradeOnSIGUSR2 bool
 	// DebugLogs enables debug level logging.
 	DebugLogs bool
 
@@ -282,6 +478,11 @@ type Config struct {
 	// connections to Cloud SQL instances instead of exiting on startup.
 	// This only applies to Unix sockets.
 	SkipFailedInstanceConfig bool
+
+	// EngineVersionCache is the path to a file that caches the engine
+	// version of each instance, so that sockets can be mounted without
+	// calling the Admin API. If empty, no cache is used.
+	EngineVersionCache string
 }
 
 // dialOptions interprets appropriate dial options for a particular instance
@@ -347,6 +548,44 @@ func (c *Config) iamAuthNEnabled() bool {
 			return true
 		}
 	}
+	for _, r := range c.FUSEInstanceRules {
+		if r.Settings.IAMAuthN != nil && *r.Settings.IAMAuthN {
+			return true
+		}
+	}
+	return false
+}
+
+// fuseInstanceConfig returns the configuration for a socket created in FUSE
+// mode, using the settings of the first rule that matches the instance.
+func (c *Config) fuseInstanceConfig(inst string) InstanceConnConfig {
+	for _, r := range c.FUSEInstanceRules {
+		if ok, _ := path.Match(r.Pattern, inst); !ok {
+			continue
+		}
+		ic := r.Settings
+		ic.Name = inst
+		ic.Addr, ic.Port, ic.UnixSocket, ic.UnixSocketPath, ic.UnixSocketPort = "", 0, "", "", 0
+		return ic
+	}
+	return InstanceConnConfig{Name: inst}
+}
+
+// fuseAllowed reports whether the instance may be looked up in FUSE mode.
+func (c *Config) fuseAllowed(inst string) bool {
+	if matchAny(c.FUSEDeny, inst) {
+		return false
+	}
+	return len(c.FUSEAllow) == 0 || matchAny(c.FUSEAllow, inst)
+}
+
+// matchAny reports whether the name matches any of the patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
 	return false
 }
 
@@ -493,7 +732,10 @@ func (c *Config) DialerOptions(l cloudsql.Logger) ([]cloudsqlconn.Option, error)
 	return opts, nil
 }
 
+// portConfig assigns ports to instances. It is safe for concurrent use, as
+// instances mounted after startup may be mounted concurrently.
 type portConfig struct {
+	mu        sync.Mutex
 	global    int
 	postgres  int
 	mysql     int
@@ -511,12 +753,16 @@ func newPortConfig(global int) *portConfig {
 
 // nextPort returns the next port based on the initial global value.
 func (c *portConfig) nextPort() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	p := c.global
 	c.global++
 	return p
 }
 
 func (c *portConfig) nextDBPort(version string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	switch {
 	case strings.HasPrefix(version, "MYSQL"):
 		p := c.mysql
@@ -532,7 +778,9 @@ func (c *portConfig) nextDBPort(version string) int {
 		return p
 	default:
 		// Unexpected engine version, use global port setting instead.
-		return c.nextPort()
+		p := c.global
+		c.global++
+		return p
 	}
 }
 
@@ -543,13 +791,76 @@ type Client struct {
 	// all Cloud SQL instances.
 	connCount uint64
 
+	// connLimit enforces the maximum number of connections across all
+	// instances.
+	connLimit *connLimiter
+	// lastQueueWait is how long the most recently queued connection waited
+	// for a free slot.
+	lastQueueWait atomic.Int64
+
+	// conns tracks the active connections across all instances.
+	conns connRegistry
+
+	// dialFailures tracks the current run of failed dials.
+	dialFailures failureRun
+
+	// closed is set once Close has been called, after which no instances are
+	// mounted. It is guarded by mntsMu.
+	closed bool
+	// closing is closed once Close has been called, so that dials stop
+	// retrying during shutdown.
+	closing chan struct{}
+
+	// engineCache persists engine versions when an engine version cache is
+	// configured.
+	engineCache *engineCache
+
+	// dirLock locks the Unix socket directory when configured. It is guarded
+	// by mntsMu once the Client is serving.
+	dirLock *dirLock
+
+	// handedOff is set once the listeners have been handed to another
+	// process, after which no instances are mounted. It is guarded by mntsMu.
+	handedOff bool
+
+	// activated holds the pre-opened listeners that have not been used by a
+	// mount yet, keyed by instance connection name. It is guarded by mntsMu
+	// once the Client is serving.
+	activated map[string]net.Listener
+	// inherited holds the listeners handed over by an upgraded process that
+	// have not been used by a mount yet. It is guarded by mntsMu once the
+	// Client is serving.
+	inherited map[string]net.Listener
+
 	// conf is the configuration used to initialize the Client.
 	conf *Config
 
 	dialer cloudsql.Dialer
 
+	// mntsMu protects mnts, pending, configured, serveCtx, and exitCh. It is
+	// never held while mounting a socket, which may call the Admin API, so
+	// that a slow mount does not block the health checks.
+	mntsMu sync.Mutex
 	// mnts is a list of all mounted sockets for this client
 	mnts []*socketMount
+	// pending holds the instances skipped at startup because their sockets
+	// could not be mounted.
+	pending []*pendingInstance
+	// configured holds the names of the instances from the configuration,
+	// as opposed to those added with AddInstance, so that UpdateInstances
+	// leaves the latter alone.
+	configured map[string]bool
+	// mountMu serializes adding instances after startup, so that an instance
+	// is not mounted twice.
+	mountMu sync.Mutex
+	// pc assigns ports to instances mounted after startup.
+	pc *portConfig
+	// drain records the instances that stopped accepting new connections.
+	drain drainState
+	// serveCtx and exitCh are set once Serve has been called, so that mounts
+	// added at runtime are served alongside the initial mounts.
+	serveCtx context.Context
+	exitCh   chan error
 
 	logger cloudsql.Logger
 
@@ -579,28 +890,71 @@ func NewClient(ctx context.Context, d cloudsql.Dialer, l cloudsql.Logger, conf *
 		dialer:           d,
 		connRefuseNotify: connRefuseNotify,
 		conf:             conf,
+		connLimit:        newConnLimiter(conf.MaxConnections),
+		closing:          make(chan struct{}),
+	}
+
+	if err := initMetrics(); err != nil {
+		l.Errorf("%v", err)
+	}
+	if conf.EngineVersionCache != "" {
+		c.engineCache = loadEngineCache(conf.EngineVersionCache, l)
 	}
 
 	if conf.FUSEDir != "" {
 		return configureFUSE(c, conf)
 	}
 
+	if len(conf.Listeners) > 0 {
+		c.activated = make(map[string]net.Listener, len(conf.Listeners))
+		for name, ln := range conf.Listeners {
+			c.activated[name] = ln
+		}
+	}
+	if len(conf.InheritedListeners) > 0 {
+		c.inherited = make(map[string]net.Listener, len(conf.InheritedListeners))
+		for name, ln := range conf.InheritedListeners {
+			c.inherited[name] = ln
+		}
+	}
+
+	if conf.UnixSocketLock && conf.UnixSocket != "" {
+		dl, err := lockDir(conf.UnixSocket)
+		if err != nil {
+			return nil, fmt.Errorf("unable to lock %v: %v", conf.UnixSocket, err)
+		}
+		c.dirLock = dl
+	}
+
 	// unless the proxy is in SqlDataEnabled mode, initiate a refresh operation to warm the cache
 	for _, inst := range conf.Instances {
 		// Skip instances with SqlDataEnabled
 		if conf.SQLDataEnabled || inst.SQLDataEnabled != nil && *inst.SQLDataEnabled {
 			continue
 		}
-		go func(name string) { _, _ = d.EngineVersion(ctx, name) }(inst.Name)
+		go func(name string) {
+			// Keep any cached engine version up to date.
+			if v, err := d.EngineVersion(ctx, name); err == nil {
+				c.engineCache.put(name, v)
+			}
+		}(inst.Name)
 	}
 
 	var mnts []*socketMount
-	pc := newPortConfig(conf.Port)
+	c.pc = newPortConfig(conf.Port)
 	for _, inst := range conf.Instances {
-		m, err := c.newSocketMount(ctx, conf, pc, inst)
+		m, err := c.newSocketMount(ctx, conf, c.pc, inst)
 		if err != nil {
 			if conf.SkipFailedInstanceConfig {
-				l.Errorf("[%v] Unable to mount socket: %v (skipped due to skip-failed-instance-config flag)", inst.Name, err)
+				p := newPendingInstance(inst, err)
+				if p.failed {
+					withFields(l, logKeyInstance, inst.Name).Errorf(
+						"Unable to mount socket: %v (skipped due to skip-failed-instance-config flag, not retrying)", err)
+				} else {
+					withFields(l, logKeyInstance, inst.Name).Errorf(
+						"Unable to mount socket: %v (skipped due to skip-failed-instance-config flag, retrying in the background)", err)
+				}
+				c.pending = append(c.pending, p)
 				continue
 			}
 
@@ -610,34 +964,370 @@ func NewClient(ctx context.Context, d cloudsql.Dialer, l cloudsql.Logger, conf *
 					l.Errorf("failed to close mount: %v", mErr)
 				}
 			}
+			if uErr := c.dirLock.unlock(); uErr != nil {
+				l.Errorf("failed to unlock %v: %v", conf.UnixSocket, uErr)
+			}
 			return nil, fmt.Errorf("[%v] Unable to mount socket: %v", inst.Name, err)
 		}
 
-		l.Infof("[%s] Listening on %s", inst.Name, m.Addr())
+		m.logger.Infof("Listening on %s", m.Addr())
 		mnts = append(mnts, m)
 	}
 	c.mnts = mnts
+	// Close any handed over listeners that no instance uses, so clients are
+	// not left waiting on a listener that is never served.
+	for name, ln := range c.inherited {
+		withFields(l, logKeyInstance, name).Infof(
+			"Closing the handed over listener, the instance is no longer configured")
+		_ = ln.Close()
+		delete(c.inherited, name)
+	}
+	c.configured = make(map[string]bool, len(conf.Instances))
+	for _, inst := range conf.Instances {
+		c.configured[inst.Name] = true
+	}
 	return c, nil
 }
 
+// AddInstance mounts a socket for the provided instance while the Client is
+// running. If the Client is already serving, the new socket starts accepting
+// connections immediately. AddInstance is not supported in FUSE mode.
+func (c *Client) AddInstance(ctx context.Context, inst InstanceConnConfig) (net.Addr, error) {
+	if c.fuseDir != "" {
+		return nil, errors.New("cannot add instances in FUSE mode")
+	}
+	if _, err := parseConnName(inst.Name); err != nil {
+		return nil, err
+	}
+
+	return c.addInstance(ctx, inst, c.pc)
+}
+
+// addInstance mounts a socket for the instance, assigning any port with pc.
+func (c *Client) addInstance(ctx context.Context, inst InstanceConnConfig, pc *portConfig) (net.Addr, error) {
+	c.mountMu.Lock()
+	defer c.mountMu.Unlock()
+	if err := c.checkAddable(inst.Name); err != nil {
+		return nil, err
+	}
+	m, err := c.newSocketMount(ctx, c.conf, pc, inst)
+	if err != nil {
+		return nil, &MountError{Instance: inst.Name, Err: err}
+	}
+
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	if err := c.checkMountable(); err != nil {
+		m.Close()
+		return nil, err
+	}
+	m.logger.Infof("Listening on %s", m.Addr())
+	c.mnts = append(c.mnts, m)
+	if c.serveCtx != nil {
+		c.serve(c.serveCtx, m, c.exitCh)
+	}
+	return m.Addr(), nil
+}
+
+// checkAddable reports an error if the named instance cannot be mounted
+// because it is already mounted, the listeners were handed off, or the Client
+// is closed. Mounting the instance replaces any pending retry.
+func (c *Client) checkAddable(name string) error {
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	if err := c.checkMountable(); err != nil {
+		return err
+	}
+	for _, m := range c.mnts {
+		if m.inst == name {
+			return fmt.Errorf("[%v] %w", name, ErrInstanceMounted)
+		}
+	}
+	c.removePending(name)
+	return nil
+}
+
+// checkMountable reports an error if no instances can be mounted because the
+// listeners were handed off or the Client is closed. The caller must hold
+// mntsMu.
+func (c *Client) checkMountable() error {
+	switch {
+	case c.handedOff:
+		return errHandedOff
+	case c.closed:
+		return errClientClosed
+	}
+	return nil
+}
+
+// ErrInstanceMounted is returned by AddInstance when the instance is already
+// mounted.
+var ErrInstanceMounted = errors.New("instance is already mounted")
+
+// errHandedOff is returned when adding an instance after the listeners were
+// handed to another process.
+var errHandedOff = errors.New("cannot add instances after handing off listeners")
+
+// errClientClosed is returned when adding an instance after the Client was
+// closed.
+var errClientClosed = errors.New("cannot add instances after the proxy is closed")
+
+// MountError is returned by AddInstance when a socket cannot be mounted for a
+// valid instance, e.g., because the Admin API or the listener failed.
+type MountError struct {
+	Instance string
+	Err      error
+}
+
+func (e *MountError) Error() string {
+	return fmt.Sprintf("[%v] Unable to mount socket: %v", e.Instance, e.Err)
+}
+
+func (e *MountError) Unwrap() error {
+	return e.Err
+}
+
+// RemoveInstance stops listening for new connections to the named instance.
+// Connections that are already open are left to drain and close on their own.
+func (c *Client) RemoveInstance(name string) error {
+	if c.fuseDir != "" {
+		return errors.New("cannot remove instances in FUSE mode")
+	}
+
+	c.mntsMu.Lock()
+	if c.removePending(name) {
+		c.mntsMu.Unlock()
+		withFields(c.logger, logKeyInstance, name).Infof("Stopped retrying to mount socket")
+		return nil
+	}
+	var (
+		removed []*socketMount
+		kept    []*socketMount
+	)
+	for _, m := range c.mnts {
+		if m.inst == name {
+			removed = append(removed, m)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	c.mnts = kept
+	c.mntsMu.Unlock()
+
+	if len(removed) == 0 {
+		return fmt.Errorf("[%v] instance is not mounted", name)
+	}
+	var mErr MultiErr
+	for _, m := range removed {
+		m.removed.Store(true)
+		if err := m.Close(); err != nil {
+			mErr = append(mErr, err)
+		}
+		m.logger.Infof(
+			"Stopped listening on %s, draining %d open connection(s)",
+			m.Addr(), m.connCount.Load(),
+		)
+	}
+	if len(mErr) > 0 {
+		return mErr
+	}
+	return nil
+}
+
+// UpdateInstances brings the mounted instances in line with insts while the
+// Client is running. Configured instances missing from insts are removed and
+// left to drain, new instances are mounted, and instances whose configuration
+// has changed are removed and mounted again on the same port. Instances whose
+// configuration is unchanged, and instances added with AddInstance that are
+// not in insts, are left untouched.
+func (c *Client) UpdateInstances(ctx context.Context, insts []InstanceConnConfig) error {
+	if c.fuseDir != "" {
+		return errors.New("cannot update instances in FUSE mode")
+	}
+	want := make(map[string]InstanceConnConfig)
+	for _, inst := range insts {
+		want[inst.Name] = inst
+	}
+	running := make(map[string]InstanceConnConfig)
+	ports := make(map[string]int)
+	for _, m := range c.mounts() {
+		running[m.inst] = m.cfg
+		if a, ok := m.Addr().(*net.TCPAddr); ok {
+			ports[m.inst] = a.Port
+		}
+	}
+	// Pending instances are left to retry unless their configuration
+	// changed.
+	c.mntsMu.Lock()
+	for _, p := range c.pending {
+		running[p.cfg.Name] = p.cfg
+	}
+	configured := c.configured
+	c.configured = make(map[string]bool, len(insts))
+	for _, inst := range insts {
+		c.configured[inst.Name] = true
+	}
+	c.mntsMu.Unlock()
+
+	var mErr MultiErr
+	for name, cfg := range running {
+		w, ok := want[name]
+		if ok && reflect.DeepEqual(w, cfg) || !ok && !configured[name] {
+			continue
+		}
+		if err := c.RemoveInstance(name); err != nil {
+			mErr = append(mErr, err)
+		}
+	}
+	for _, inst := range insts {
+		if cfg, ok := running[inst.Name]; ok && reflect.DeepEqual(inst, cfg) {
+			continue
+		}
+		pc := c.pc
+		if p, ok := ports[inst.Name]; ok {
+			// Keep a changed instance on its port, unless the new
+			// configuration sets one, so clients connect as before.
+			pc = &portConfig{global: p, postgres: p, mysql: p, sqlserver: p}
+		}
+		if _, err := c.addInstance(ctx, inst, pc); err != nil {
+			mErr = append(mErr, err)
+		}
+	}
+	if len(mErr) > 0 {
+		return mErr
+	}
+	return nil
+}
+
+// mounts returns a copy of the sockets currently mounted by the Client.
+func (c *Client) mounts() []*socketMount {
+	if c.fuseDir != "" {
+		return c.fuseMounts()
+	}
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	mnts := make([]*socketMount, len(c.mnts))
+	copy(mnts, c.mnts)
+	return mnts
+}
+
+// Instances reports the instance connection name and listening address of
+// every mounted socket, along with the instances skipped at startup that are
+// not mounted yet.
+func (c *Client) Instances() []InstanceInfo {
+	var infos []InstanceInfo
+	for _, m := range c.mounts() {
+		infos = append(infos, InstanceInfo{
+			Name:              m.inst,
+			State:             InstanceMounted,
+			Addr:              m.Addr().String(),
+			OpenConnections:   m.connCount.Load(),
+			MaxConnections:    m.connLimit.limit(),
+			QueuedConnections: m.connLimit.queueLen(),
+			Serving:           m.serving.Load(),
+			Draining:          !c.accepting(m.inst),
+		})
+	}
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	for _, p := range c.pending {
+		infos = append(infos, InstanceInfo{
+			Name:          p.cfg.Name,
+			State:         p.state(),
+			Error:         p.err.Error(),
+			MountAttempts: p.attempts,
+		})
+	}
+	return infos
+}
+
+// InstanceInfo describes a mounted instance.
+type InstanceInfo struct {
+	// Name is the instance connection name.
+	Name string `json:"name"`
+	// State is InstanceMounted once the instance has a listening socket,
+	// InstancePending while a failed mount is retried, or InstanceFailed if
+	// the mount cannot succeed on a retry.
+	State string `json:"state"`
+	// Error is the last error mounting the instance, if it is not mounted.
+	Error string `json:"error,omitempty"`
+	// MountAttempts is the number of attempts to mount the instance, if it
+	// is not mounted.
+	MountAttempts int `json:"mountAttempts,omitempty"`
+	// Addr is the address of the instance's listener.
+	Addr string `json:"address"`
+	// OpenConnections is the number of open connections to the instance.
+	OpenConnections uint64 `json:"openConnections"`
+	// MaxConnections is the connection limit for the instance. Zero means no
+	// limit.
+	MaxConnections uint64 `json:"maxConnections,omitempty"`
+	// QueuedConnections is the number of connections waiting for a free slot.
+	QueuedConnections uint64 `json:"queuedConnections,omitempty"`
+	// Serving reports whether the instance's listener is accepting
+	// connections.
+	Serving bool `json:"serving"`
+	// Draining reports whether new connections to the instance are refused
+	// because of Drain.
+	Draining bool `json:"draining,omitempty"`
+}
+
 // CheckConnections dials each registered instance and reports the number of
 // connections checked and any errors that may have occurred.
 func (c *Client) CheckConnections(ctx context.Context) (int, error) {
+	checks := c.checkMounts(ctx)
+	var mErr MultiErr
+	for _, ch := range checks {
+		if ch.err != nil {
+			mErr = append(mErr, ch.err)
+		}
+	}
+	mLen := len(checks)
+	if len(mErr) > 0 {
+		return mLen, mErr
+	}
+	return mLen, nil
+}
+
+// CheckInstanceConnections dials each registered instance and reports the
+// result by instance connection name. A nil error means the instance was
+// reachable.
+func (c *Client) CheckInstanceConnections(ctx context.Context) map[string]error {
+	res := make(map[string]error)
+	for _, ch := range c.checkMounts(ctx) {
+		// An instance may be mounted more than once. Any failure wins.
+		if err, ok := res[ch.inst]; !ok || err == nil {
+			res[ch.inst] = ch.err
+		}
+	}
+	return res
+}
+
+// mountCheck is the result of dialing the instance of a mount.
+type mountCheck struct {
+	inst string
+	err  error
+}
+
+// checkMounts dials the instance of every mount concurrently.
+func (c *Client) checkMounts(ctx context.Context) []mountCheck {
 	var (
-		wg    sync.WaitGroup
-		errCh = make(chan error, len(c.mnts))
-		mnts  = c.mnts
+		wg     sync.WaitGroup
+		mnts   = c.mounts()
+		checks = make([]mountCheck, len(mnts))
 	)
-	if c.fuseDir != "" {
-		mnts = c.fuseMounts()
-	}
-	for _, mnt := range mnts {
+	for i, mnt := range mnts {
 		wg.Add(1)
-		go func(m *socketMount) {
+		go func(i int, m *socketMount) {
 			defer wg.Done()
+			checks[i].inst = m.inst
+			if m.restarting.Load() {
+				checks[i].err = fmt.Errorf("[%v] listener failed and is restarting", m.inst)
+				return
+			}
 			conn, err := c.dialer.Dial(ctx, m.inst, m.dialOpts...)
+			c.recordDialResult(err)
 			if err != nil {
-				errCh <- err
+				checks[i].err = err
 				return
 			}
 			cErr := conn.Close()
@@ -647,24 +1337,10 @@ func (c *Client) CheckConnections(ctx context.Context) (int, error) {
 					m.inst, cErr,
 				)
 			}
-		}(mnt)
+		}(i, mnt)
 	}
 	wg.Wait()
-
-	var mErr MultiErr
-	for i := 0; i < len(mnts); i++ {
-		select {
-		case err := <-errCh:
-			mErr = append(mErr, err)
-		default:
-			continue
-		}
-	}
-	mLen := len(mnts)
-	if len(mErr) > 0 {
-		return mLen, mErr
-	}
-	return mLen, nil
+	return checks
 }
 
 // ConnCount returns the number of open connections and the maximum allowed
@@ -673,6 +1349,27 @@ func (c *Client) ConnCount() (uint64, uint64) {
 	return atomic.LoadUint64(&c.connCount), c.conf.MaxConnections
 }
 
+// ConnQueue returns the number of connections waiting for a free slot across
+// the global and per-instance connection limits, and how long the most
+// recently queued connection waited.
+func (c *Client) ConnQueue() (uint64, time.Duration) {
+	queued := c.connLimit.queueLen()
+	for _, m := range c.mounts() {
+		queued += m.connLimit.queueLen()
+	}
+	return queued, time.Duration(c.lastQueueWait.Load())
+}
+
+// Connections reports the details of every active connection.
+func (c *Client) Connections() []ConnInfo {
+	return c.conns.list()
+}
+
+// CloseConnection forcibly closes the active connection with the provided ID.
+func (c *Client) CloseConnection(id uint64) error {
+	return c.conns.closeConn(id)
+}
+
 // Serve starts proxying connections for all configured instances using the
 // associated socket.
 func (c *Client) Serve(ctx context.Context, notify func()) error {
@@ -693,27 +1390,53 @@ func (c *Client) Serve(ctx context.Context, notify func()) error {
 	}
 
 	exitCh := make(chan error)
+	c.mntsMu.Lock()
+	c.serveCtx = ctx
+	c.exitCh = exitCh
 	for _, m := range c.mnts {
-		go func(mnt *socketMount) {
-			err := c.serveSocketMount(ctx, mnt)
-			if err != nil {
-				select {
-				// Best effort attempt to send error.
-				// If this send fails, it means the reading goroutine has
-				// already pulled a value out of the channel and is no longer
-				// reading any more values. In other words, we report only the
-				// first error.
-				case exitCh <- err:
-				default:
-					return
-				}
-			}
-		}(m)
+		c.serve(ctx, m, exitCh)
 	}
+	if len(c.pending) > 0 {
+		go c.retryPending(ctx)
+	}
+	c.mntsMu.Unlock()
 	notify()
 	return <-exitCh
 }
 
+// serve starts serving the socket mount in a separate goroutine and reports
+// any error on exitCh once the mount is closed, unless the mount was removed
+// intentionally. A failed listener is restarted without affecting other
+// mounts.
+func (c *Client) serve(ctx context.Context, m *socketMount, exitCh chan<- error) {
+	go func() {
+		err := c.serveWithRestart(ctx, m)
+		if err != nil {
+			select {
+			// Best effort attempt to send error.
+			// If this send fails, it means the reading goroutine has
+			// already pulled a value out of the channel and is no longer
+			// reading any more values. In other words, we report only the
+			// first error.
+			case exitCh <- err:
+			default:
+				return
+			}
+		}
+	}()
+}
+
+// closeIdleConns closes connections with idle sessions when
+// transaction-aware shutdown is enabled.
+func (c *Client) closeIdleConns() {
+	if !c.conf.TransactionAwareShutdown {
+		return
+	}
+	if n := c.conns.closeIdle(); n > 0 {
+		c.logger.Infof("Closed %d idle connection(s) for shutdown", n)
+	}
+}
+
 // MultiErr is a group of errors wrapped into one.
 type MultiErr []error
 
@@ -732,7 +1455,17 @@ func (m MultiErr) Error() string {
 
 // Close triggers the proxyClient to shut down.
 func (c *Client) Close() error {
+	c.mntsMu.Lock()
+	// Stop mounting instances, so that every mount is closed below.
+	c.closed = true
+	// Stop retrying dials.
+	select {
+	case <-c.closing:
+	default:
+		close(c.closing)
+	}
 	mnts := c.mnts
+	c.mntsMu.Unlock()
 	var mErr MultiErr
 
 	// If FUSE is enabled, unmount it and save a reference to any existing
@@ -751,13 +1484,17 @@ func (c *Client) Close() error {
 	}
 
 	// Start a timer for clean shutdown (where all connections are closed).
-	// While the timer runs, additional connections will be accepted.
+	// While the timer runs, additional connections will be accepted. With
+	// transaction-aware shutdown, connections are closed as soon as their
+	// sessions are idle outside of a transaction.
 	timeout := time.After(c.conf.WaitOnClose)
 	t := time.NewTicker(100 * time.Millisecond)
 	defer t.Stop()
+	c.closeIdleConns()
 	for {
 		select {
 		case <-t.C:
+			c.closeIdleConns()
 			if atomic.LoadUint64(&c.connCount) > 0 {
 				continue
 			}
@@ -775,6 +1512,16 @@ func (c *Client) Close() error {
 	if c.fuseDir != "" {
 		c.waitForFUSEMounts()
 	}
+	// Close any pre-opened listeners that no instance used.
+	c.mntsMu.Lock()
+	if err := c.dirLock.unlock(); err != nil {
+		mErr = append(mErr, err)
+	}
+	for name, ln := range c.activated {
+		_ = ln.Close()
+		delete(c.activated, name)
+	}
+	c.mntsMu.Unlock()
 	// Verify that all connections are closed.
 	open := atomic.LoadUint64(&c.connCount)
 	if c.conf.WaitOnClose > 0 && open > 0 {
@@ -790,58 +1537,171 @@ func (c *Client) Close() error {
 
 // serveSocketMount persistently listens to the socketMounts listener and proxies connections to a
 // given Cloud SQL instance.
-func (c *Client) serveSocketMount(_ context.Context, s *socketMount) error {
+func (c *Client) serveSocketMount(ctx context.Context, s *socketMount) error {
+	s.serving.Store(true)
+	defer s.serving.Store(false)
 	for {
 		cConn, err := s.Accept()
 		if err != nil {
+			if !s.removed.Load() {
+				s.acceptFailures.fail()
+			}
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-				c.logger.Errorf("[%s] Error accepting connection: %v", s.inst, err)
+				s.logger.Errorf("Error accepting connection: %v", err)
 				// For transient errors, wait a small amount of time to see if it resolves itself
 				time.Sleep(10 * time.Millisecond)
 				continue
 			}
 			return err
 		}
+		s.acceptFailures.succeed()
+		s.touch()
+		if !c.accepting(s.inst) {
+			withFields(s.logger, logKeyClientAddr, cConn.RemoteAddr().String()).Infof(
+				"Refused connection, the instance is draining")
+			_ = cConn.Close()
+			continue
+		}
 		// handle the connection in a separate goroutine
 		go func() {
-			c.logger.Infof("[%s] Accepted connection from %s", s.inst, cConn.RemoteAddr())
+			l := withFields(s.logger, logKeyClientAddr, cConn.RemoteAddr().String())
+			l.Infof("Accepted connection")
 
 			// A client has established a connection to the local socket. Before
-			// we initiate a connection to the Cloud SQL backend, increment the
-			// connection counter. If the total number of connections exceeds
-			// the maximum, refuse to connect and close the client connection.
-			count := atomic.AddUint64(&c.connCount, 1)
-			defer atomic.AddUint64(&c.connCount, ^uint64(0))
-
-			if c.conf.MaxConnections > 0 && count > c.conf.MaxConnections {
-				c.logger.Infof("max connections (%v) exceeded, refusing new connection", c.conf.MaxConnections)
+			// we initiate a connection to the Cloud SQL backend, reserve a
+			// connection slot for the instance and for the Client. If either
+			// limit is reached and no slot frees up in time, refuse to connect
+			// and close the client connection.
+			release, ok := c.admitConn(s)
+			if !ok {
+				recordConnRefused(s.inst)
 				if c.connRefuseNotify != nil {
 					go c.connRefuseNotify()
 				}
 				_ = cConn.Close()
 				return
 			}
+			defer release()
+			recordConnAccepted(s.inst)
 
-			// give a max of 30 seconds to connect to the instance
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
+			atomic.AddUint64(&c.connCount, 1)
+			defer atomic.AddUint64(&c.connCount, ^uint64(0))
+			recordOpenConnections(s.inst, s.connCount.Add(1))
+			defer func() {
+				recordOpenConnections(s.inst, s.connCount.Add(^uint64(0)))
+				s.touch()
+			}()
 
-			sConn, err := c.dialer.Dial(ctx, s.inst, s.dialOpts...)
+			sConn, err := c.dial(ctx, s)
 			if err != nil {
-				c.logger.Errorf("[%s] failed to connect to instance: %v", s.inst, err)
+				l.Errorf("failed to connect to instance: %v", err)
 				_ = cConn.Close()
 				return
 			}
-			c.proxyConn(s.inst, cConn, sConn)
+			c.proxyConn(s, cConn, sConn)
 		}()
 	}
 }
 
+// admitConn reserves a connection slot for the socket mount and for the
+// Client, waiting in a queue if a queue timeout is configured. It reports
+// whether the connection may proceed and returns a function that frees the
+// reserved slots.
+func (c *Client) admitConn(s *socketMount) (func(), bool) {
+	wait := c.conf.ConnQueueTimeout
+	if s.cfg.ConnQueueTimeout != nil {
+		wait = *s.cfg.ConnQueueTimeout
+	}
+	instWait, ok := s.connLimit.acquire(wait)
+	if !ok {
+		c.recordQueueWait(instWait)
+		s.logger.Infof("max connections (%v) for instance exceeded, refusing new connection",
+			s.connLimit.limit())
+		return nil, false
+	}
+	globalWait, ok := c.connLimit.acquire(wait - instWait)
+	c.recordQueueWait(instWait + globalWait)
+	if !ok {
+		s.connLimit.release()
+		c.logger.Infof("max connections (%v) exceeded, refusing new connection", c.conf.MaxConnections)
+		return nil, false
+	}
+	return func() {
+		c.connLimit.release()
+		s.connLimit.release()
+	}, true
+}
+
+// recordQueueWait saves the time a connection spent waiting for a free slot.
+func (c *Client) recordQueueWait(d time.Duration) {
+	if d > 0 {
+		c.lastQueueWait.Store(int64(d))
+	}
+}
+
 // socketMount is a tcp/unix socket that listens for a Cloud SQL instance.
 type socketMount struct {
-	inst     string
-	listener net.Listener
-	dialOpts []cloudsqlconn.DialOption
+	inst string
+	// cfg is the instance configuration used to create the mount.
+	cfg InstanceConnConfig
+	// listenerMu protects listener and closed.
+	listenerMu sync.Mutex
+	listener   net.Listener
+	// closed is set once the mount is closed, so a failed listener is not
+	// restarted.
+	closed bool
+	// restarting is set while a failed listener is being restarted.
+	restarting atomic.Bool
+	// perms is the mode and ownership given to a Unix socket when it is
+	// created or restarted.
+	perms unixSocketPerms
+	// activated is set when the listener was passed by socket activation,
+	// so it cannot be restarted.
+	activated bool
+	dialOpts  []cloudsqlconn.DialOption
+	// logger adds the instance to every message about the mount.
+	logger cloudsql.Logger
+	// dialSettings holds the dial timeout and retry settings.
+	dialSettings dialSettings
+	// timeouts holds the idle timeout and maximum lifetime of connections.
+	timeouts connTimeouts
+	// engineMu protects engine.
+	engineMu sync.Mutex
+	// engine is the database engine version of the instance, once it has
+	// been resolved for transaction-aware shutdown.
+	engine string
+	// connCount tracks the number of open connections for this mount.
+	connCount atomic.Uint64
+	// connLimit enforces the maximum number of connections for this mount.
+	connLimit *connLimiter
+	// removed is set when the mount has been removed at runtime, so that the
+	// resulting accept error does not shut down the Client.
+	removed atomic.Bool
+	// handedOver is set when the listener was handed to another process by
+	// ListenerFiles, so its socket is kept when the mount is closed.
+	handedOver atomic.Bool
+	// serving is set while the accept loop is running.
+	serving atomic.Bool
+	// acceptFailures tracks the current run of failed accepts, including
+	// failed attempts to restart the listener.
+	acceptFailures failureRun
+	// lastUsed is when the mount last accepted or closed a connection, in
+	// Unix nanoseconds.
+	lastUsed atomic.Int64
+}
+
+// touch marks the mount as used now.
+func (s *socketMount) touch() {
+	s.lastUsed.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long the mount has had no open connections, or zero
+// if a connection is open.
+func (s *socketMount) idleFor(now time.Time) time.Duration {
+	if s.connCount.Load() > 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, s.lastUsed.Load()))
 }
 
 func networkType(conf *Config, inst InstanceConnConfig) string {
@@ -853,6 +1713,56 @@ func networkType(conf *Config, inst InstanceConnConfig) string {
 }
 
 func (c *Client) newSocketMount(ctx context.Context, conf *Config, pc *portConfig, inst InstanceConnConfig) (*socketMount, error) {
+	var (
+		l     = withFields(c.logger, logKeyInstance, inst.Name)
+		perms = newUnixSocketPerms(conf)
+	)
+	ln, activated := c.takePreopened(inst.Name)
+	switch {
+	case activated:
+		l.Infof("Using the listener passed by socket activation")
+	case ln != nil:
+		l.Infof("Using the listener handed over by the upgraded process")
+	default:
+		var err error
+		ln, err = c.listenInstance(ctx, conf, pc, inst, l, perms)
+		if err != nil {
+			return nil, err
+		}
+	}
+	opts := dialOptions(*conf, inst)
+	m := &socketMount{
+		inst:         inst.Name,
+		cfg:          inst,
+		dialOpts:     opts,
+		logger:       l,
+		dialSettings: newDialSettings(conf, inst),
+		timeouts:     newConnTimeouts(conf, inst),
+		listener:     ln,
+		perms:        perms,
+		activated:    activated,
+		connLimit:    newConnLimiter(inst.MaxConnections),
+	}
+	return m, nil
+}
+
+// takePreopened returns the pre-opened listener for the instance, if any, and
+// whether it was passed by socket activation. A pre-opened listener can only
+// be used once.
+func (c *Client) takePreopened(name string) (net.Listener, bool) {
+	c.mntsMu.Lock()
+	defer c.mntsMu.Unlock()
+	if ln, ok := c.activated[name]; ok {
+		delete(c.activated, name)
+		return ln, true
+	}
+	ln := c.inherited[name]
+	delete(c.inherited, name)
+	return ln, false
+}
+
+// listenInstance creates the TCP or Unix socket listener for the instance.
+func (c *Client) listenInstance(ctx context.Context, conf *Config, pc *portConfig, inst InstanceConnConfig, l cloudsql.Logger, perms unixSocketPerms) (net.Listener, error) {
 	var (
 		// network is one of "tcp" or "unix"
 		network string
@@ -891,15 +1801,11 @@ func (c *Client) newSocketMount(ctx context.Context, conf *Config, pc *portConfi
 			np = inst.Port
 		case conf.Port != 0:
 			np = pc.nextPort()
-		case conf.SQLDataEnabled || inst.SQLDataEnabled != nil && *inst.SQLDataEnabled:
-			// TODO: Only Postgres is supported by the SqlDataService
-			// when more engines are supported, this code will need to change.
-			np = pc.nextDBPort("POSTGRES")
 		default:
-			version, err := c.dialer.EngineVersion(ctx, inst.Name)
+			version, err := c.engineVersion(ctx, conf, inst)
 			// Exit if the port is not specified for inactive instance
 			if err != nil {
-				c.logger.Errorf("[%v] could not resolve instance version: %v", inst.Name, err)
+				l.Errorf("could not resolve instance version: %v", err)
 				return nil, err
 			}
 			np = pc.nextDBPort(version)
@@ -909,51 +1815,38 @@ func (c *Client) newSocketMount(ctx context.Context, conf *Config, pc *portConfi
 	} else {
 		network = "unix"
 
-		var version string
-		switch {
-		case conf.SQLDataEnabled || inst.SQLDataEnabled != nil && *inst.SQLDataEnabled:
-			version = "POSTGRES"
-		default:
-			var err error
-			version, err = c.dialer.EngineVersion(ctx, inst.Name)
-			if err != nil {
-				c.logger.Errorf("[%v] could not resolve instance version: %v", inst.Name, err)
-				return nil, err
-			}
+		version, err := c.engineVersion(ctx, conf, inst)
+		if err != nil {
+			l.Errorf("could not resolve instance version: %v", err)
+			return nil, err
 		}
 
-		address, err = newUnixSocketMount(inst, conf.UnixSocket, strings.HasPrefix(version, "POSTGRES"))
+		address, err = newUnixSocketMount(inst, conf.UnixSocket, conf.UnixSocketNameTemplate, version)
 		if err != nil {
-			c.logger.Errorf("[%v] could not mount unix socket %q: %v", inst.Name, conf.UnixSocket, err)
+			l.Errorf("could not mount unix socket %q: %v", conf.UnixSocket, err)
 			return nil, err
 		}
 	}
 
-	lc := net.ListenConfig{KeepAlive: 30 * time.Second}
-	ln, err := lc.Listen(ctx, network, address)
+	ln, err := listen(ctx, l, network, address, perms)
 	if err != nil {
-		c.logger.Errorf("[%v] could not listen to address %v: %v", inst.Name, address, err)
+		l.Errorf("could not listen to address %v: %v", address, err)
 		return nil, err
 	}
-	// Change file permissions to allow access for user, group, and other.
-	if network == "unix" {
-		// Best effort. If this call fails, group and other won't have write
-		// access.
-		_ = os.Chmod(address, 0777)
-	}
-	opts := dialOptions(*conf, inst)
-	m := &socketMount{inst: inst.Name, dialOpts: opts, listener: ln}
-	return m, nil
+	return ln, nil
 }
 
 // newUnixSocketMount parses the configuration and returns the path to the unix
-// socket, or an error if that path is not valid.
-func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres bool) (string, error) {
+// socket, or an error if that path is not valid. Sockets in a directory are
+// named with the template, or for the port if the instance sets one.
+func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir, tmpl, version string) (string, error) {
 	var (
 		// the path to the unix socket
 		address string
 		// the parent directory of the unix socket
 		dir string
+
+		postgres = strings.HasPrefix(version, "POSTGRES")
 	)
 
 	if inst.UnixSocketPath != "" {
@@ -972,7 +1865,11 @@ func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres
 		if dir == "" {
 			dir = inst.UnixSocket
 		}
-		address = UnixAddress(dir, inst.Name)
+		name, err := UnixSocketName(tmpl, inst.Name)
+		if err != nil {
+			return "", err
+		}
+		address = UnixAddress(dir, name)
 	}
 
 	// if base directory does not exist, fail
@@ -980,6 +1877,19 @@ func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres
 		return "", err
 	}
 
+	// When a port is set, create the socket directly in the directory and
+	// name it the way clients do when given the directory and port.
+	if inst.UnixSocketPath == "" && inst.UnixSocketPort != 0 {
+		switch {
+		case postgres:
+			return UnixAddress(dir, fmt.Sprintf(".s.PGSQL.%d", inst.UnixSocketPort)), nil
+		case strings.HasPrefix(version, "MYSQL"):
+			return UnixAddress(dir, fmt.Sprintf(".s.MYSQL.%d", inst.UnixSocketPort)), nil
+		default:
+			return "", fmt.Errorf("unix socket port is only supported for Postgres and MySQL, got: %v", version)
+		}
+	}
+
 	// When setting up a listener for Postgres, create address as a
 	// directory, and use the Postgres-specific socket name
 	// .s.PGSQL.5432.
@@ -997,81 +1907,151 @@ func newUnixSocketMount(inst InstanceConnConfig, unixSocketDir string, postgres
 }
 
 func (s *socketMount) Addr() net.Addr {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
 	return s.listener.Addr()
 }
 
 func (s *socketMount) Accept() (net.Conn, error) {
-	return s.listener.Accept()
+	s.listenerMu.Lock()
+	l := s.listener
+	s.listenerMu.Unlock()
+	return l.Accept()
 }
 
 // Close stops the mount from listening for any more connections
 func (s *socketMount) Close() error {
+	s.listenerMu.Lock()
+	defer s.listenerMu.Unlock()
+	s.closed = true
 	return s.listener.Close()
 }
 
-// proxyConn sets up a bidirectional copy between two open connections
-func (c *Client) proxyConn(inst string, client, server net.Conn) {
+// proxyConn sets up a bidirectional copy between two open connections. When
+// the client finishes sending, the instance is half-closed so it can finish
+// its response. When the instance finishes sending, both connections are
+// closed. The connections are closed early if they exceed the mount's idle
+// timeout or maximum lifetime.
+func (c *Client) proxyConn(s *socketMount, client, server net.Conn) {
+	inst := s.inst
 	// only allow the first side to give an error for terminating a connection
-	var o sync.Once
-	cleanup := func(errDesc string, isErr bool) {
+	var (
+		o           sync.Once
+		closeReason string
+	)
+	ac := c.conns.newConn(inst, client.RemoteAddr())
+	l := withFields(s.logger, logKeyConnID, ac.id, logKeyClientAddr, ac.clientAddr)
+	done := make(chan struct{})
+	cleanup := func(errDesc, reason string, isErr bool) {
 		o.Do(func() {
+			closeReason = reason
+			close(done)
 			_ = client.Close()
 			_ = server.Close()
 			if isErr {
-				c.logger.Errorf(errDesc)
+				l.Errorf("%s", errDesc)
 			} else {
-				c.logger.Infof(errDesc)
+				l.Infof("%s", errDesc)
 			}
 		})
 	}
 
+	// The first side to close the connection is reported once both
+	// directions are done.
+	var (
+		eofOnce   sync.Once
+		eofDesc   string
+		eofReason string
+	)
+	closed := func(desc, reason string) {
+		eofOnce.Do(func() {
+			eofDesc, eofReason = desc, reason
+		})
+	}
+
+	ac.close = func(desc, reason string) {
+		cleanup(desc, reason, false)
+	}
+	var clientWatch, serverWatch io.Writer
+	if t := c.newSessionTracker(s); t != nil {
+		ac.session = t
+		clientWatch, serverWatch = t.clientWriter(), t.serverWriter()
+	}
+	c.conns.add(ac)
+	defer c.conns.remove(ac.id)
+
+	br := &bytesRecorder{inst: inst, sent: &ac.bytesSent, received: &ac.bytesReceived}
+	go br.run(done)
+
+	var a *activity
+	if s.timeouts.enabled() {
+		a = &activity{}
+		a.touch()
+		start := time.Now()
+		go func() {
+			switch s.timeouts.watch(start, a, done) {
+			case closeReasonIdleTimeout:
+				cleanup(fmt.Sprintf("closing connection - idle timeout (%v) exceeded",
+					s.timeouts.idle), closeReasonIdleTimeout, false)
+			case closeReasonMaxLifetime:
+				cleanup(fmt.Sprintf("closing connection - max lifetime (%v) exceeded",
+					s.timeouts.maxLifetime), closeReasonMaxLifetime, false)
+			}
+		}()
+	}
+
 	// copy bytes from client to server
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		buf := make([]byte, 8*1024) // 8kb
-		for {
-			n, cErr := client.Read(buf)
-			var sErr error
-			if n > 0 {
-				_, sErr = server.Write(buf[:n])
-			}
-			switch {
-			case cErr == io.EOF:
-				cleanup(fmt.Sprintf("[%s] client closed the connection", inst), false)
-				return
-			case cErr != nil:
-				cleanup(fmt.Sprintf("[%s] connection aborted - error reading from client: %v", inst, cErr), true)
-				return
-			case sErr == io.EOF:
-				cleanup(fmt.Sprintf("[%s] instance closed the connection", inst), false)
-				return
-			case sErr != nil:
-				cleanup(fmt.Sprintf("[%s] connection aborted - error writing to instance: %v", inst, sErr), true)
+		defer wg.Done()
+		var (
+			rErr *readError
+			wErr *writeError
+		)
+		err := copyConn(server, client, a, &ac.bytesSent, clientWatch)
+		switch {
+		case err == nil:
+			closed("client closed the connection", closeReasonClient)
+			// Let the instance finish its response.
+			if cw, ok := closeWriterOf(server); ok && cw.CloseWrite() == nil {
 				return
 			}
+			cleanup(eofDesc, eofReason, false)
+		case errors.As(err, &rErr):
+			cleanup(fmt.Sprintf("connection aborted - error reading from client: %v", rErr.err), closeReasonError, true)
+		case errors.As(err, &wErr) && wErr.err == io.EOF:
+			cleanup("instance closed the connection", closeReasonInstance, false)
+		case errors.As(err, &wErr):
+			cleanup(fmt.Sprintf("connection aborted - error writing to instance: %v", wErr.err), closeReasonError, true)
+		default:
+			cleanup(fmt.Sprintf("connection aborted - error copying from client to instance: %v", err), closeReasonError, true)
 		}
 	}()
 
 	// copy bytes from server to client
-	buf := make([]byte, 8*1024) // 8kb
-	for {
-		n, sErr := server.Read(buf)
-		var cErr error
-		if n > 0 {
-			_, cErr = client.Write(buf[:n])
-		}
-		switch {
-		case sErr == io.EOF:
-			cleanup(fmt.Sprintf("[%s] instance closed the connection", inst), false)
-			return
-		case sErr != nil:
-			cleanup(fmt.Sprintf("[%s] connection aborted - error reading from instance: %v", inst, sErr), true)
-			return
-		case cErr == io.EOF:
-			cleanup(fmt.Sprintf("[%s] client closed the connection", inst), false)
-			return
-		case cErr != nil:
-			cleanup(fmt.Sprintf("[%s] connection aborted - error writing to client: %v", inst, cErr), true)
-			return
-		}
+	var (
+		rErr *readError
+		wErr *writeError
+	)
+	err := copyConn(client, server, a, &ac.bytesReceived, serverWatch)
+	switch {
+	case err == nil:
+		// Idle clients would otherwise hold the connection open after the
+		// instance is gone.
+		closed("instance closed the connection", closeReasonInstance)
+		cleanup(eofDesc, eofReason, false)
+	case errors.As(err, &rErr):
+		cleanup(fmt.Sprintf("connection aborted - error reading from instance: %v", rErr.err), closeReasonError, true)
+	case errors.As(err, &wErr) && wErr.err == io.EOF:
+		cleanup("client closed the connection", closeReasonClient, false)
+	case errors.As(err, &wErr):
+		cleanup(fmt.Sprintf("connection aborted - error writing to client: %v", wErr.err), closeReasonError, true)
+	default:
+		cleanup(fmt.Sprintf("connection aborted - error copying from instance to client: %v", err), closeReasonError, true)
 	}
+	<-done
+	wg.Wait()
+	br.flush()
+	recordConnClosed(inst, closeReason, time.Since(ac.start))
 }
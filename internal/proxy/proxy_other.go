@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -109,6 +110,10 @@ func (c *Client) Lookup(_ context.Context, instance string, _ *fuse.EntryOut) (*
 		c.logger.Debugf("could not parse instance connection name for %q: %v", instance, err)
 		return nil, syscall.ENOENT
 	}
+	if !c.conf.fuseAllowed(instance) {
+		c.logger.Debugf("instance %q is not allowed in FUSE mode", instance)
+		return nil, syscall.ENOENT
+	}
 
 	c.fuseMu.Lock()
 	defer c.fuseMu.Unlock()
@@ -120,22 +125,26 @@ func (c *Client) Lookup(_ context.Context, instance string, _ *fuse.EntryOut) (*
 	c.logger.Debugf("creating new socket for instance %q", instance)
 	s, err := c.newSocketMount(
 		ctx, withUnixSocket(*c.conf, c.fuseTempDir),
-		nil, InstanceConnConfig{Name: instance},
+		nil, c.conf.fuseInstanceConfig(instance),
 	)
 	if err != nil {
 		c.logger.Errorf("could not create socket for %q: %v", instance, err)
 		return nil, syscall.ENOENT
 	}
+	s.touch()
 
 	c.fuseWg.Add(1)
 	go func() {
 		defer c.fuseWg.Done()
-		sErr := c.serveSocketMount(ctx, s)
+		// A failed listener is restarted, so an error means the socket
+		// was closed.
+		sErr := c.serveWithRestart(ctx, s)
 		if sErr != nil {
 			c.logger.Debugf("could not serve socket for instance %q: %v", instance, sErr)
 			c.fuseMu.Lock()
 			defer c.fuseMu.Unlock()
 			delete(c.fuseSockets, instance)
+			recordFUSESockets(len(c.fuseSockets))
 			select {
 			// Best effort attempt to send error.
 			// If this send fails, it means the reading goroutine has
@@ -157,6 +166,7 @@ func (c *Client) Lookup(_ context.Context, instance string, _ *fuse.EntryOut) (*
 		socket:  s,
 		symlink: sl,
 	}
+	recordFUSESockets(len(c.fuseSockets))
 	return c.NewInode(ctx, sl, fs.StableAttr{
 		Mode: 0777 | fuse.S_IFLNK},
 	), fs.OK
@@ -179,6 +189,9 @@ func (c *Client) serveFuse(ctx context.Context, notify func()) error {
 	c.fuseExitCh = make(chan error)
 
 	c.fuseServerMu.Unlock()
+	if t := c.conf.FUSEIdleTimeout; t > 0 {
+		go c.closeIdleFUSESockets(ctx, t)
+	}
 	notify()
 	select {
 	case err = <-c.fuseExitCh:
@@ -188,6 +201,50 @@ func (c *Client) serveFuse(ctx context.Context, notify func()) error {
 	}
 }
 
+// closeIdleFUSESockets periodically closes and removes sockets that have had
+// no connections for the idle timeout, until ctx is done.
+func (c *Client) closeIdleFUSESockets(ctx context.Context, timeout time.Duration) {
+	t := time.NewTicker(timeout / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		for _, name := range c.removeIdleFUSESockets(time.Now(), timeout) {
+			withFields(c.logger, logKeyInstance, name).Infof(
+				"Closed FUSE socket after %v without connections", timeout)
+			// Invalidate any cached lookup so the next one creates a new
+			// socket.
+			_ = c.NotifyEntry(name)
+		}
+	}
+}
+
+// removeIdleFUSESockets closes and removes the sockets that have had no
+// connections for the idle timeout and returns their instance names.
+func (c *Client) removeIdleFUSESockets(now time.Time, timeout time.Duration) []string {
+	c.fuseMu.Lock()
+	defer c.fuseMu.Unlock()
+	var removed []string
+	for name, ss := range c.fuseSockets {
+		if ss.socket.idleFor(now) < timeout {
+			continue
+		}
+		// Close the socket while holding the lock so a new lookup of the
+		// same instance cannot create a socket that this close removes.
+		ss.socket.removed.Store(true)
+		if err := ss.socket.Close(); err != nil {
+			ss.socket.logger.Debugf("failed to close idle FUSE socket: %v", err)
+		}
+		delete(c.fuseSockets, name)
+		removed = append(removed, name)
+	}
+	recordFUSESockets(len(c.fuseSockets))
+	return removed
+}
+
 func (c *Client) fuseMounts() []*socketMount {
 	var mnts []*socketMount
 	c.fuseMu.Lock()
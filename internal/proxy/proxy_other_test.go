@@ -18,8 +18,12 @@ package proxy_test
 
 import (
 	"context"
+	"net"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
 )
@@ -60,3 +64,188 @@ func TestFuseClosesGracefully(t *testing.T) {
 	}
 
 }
+
+func TestClientSetsUnixSocketModeAndOwner(t *testing.T) {
+	testDir := t.TempDir()
+	uid, gid := os.Getuid(), os.Getgid()
+	in := &proxy.Config{
+		UnixSocket:     testDir,
+		UnixSocketMode: 0700,
+		UnixSocketUID:  &uid,
+		UnixSocketGID:  &gid,
+		Instances:      []proxy.InstanceConnConfig{{Name: mysql}},
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+
+	addr := filepath.Join(testDir, mysql)
+	fi, err := os.Stat(addr)
+	if err != nil {
+		t.Fatalf("os.Stat(%v): %v", addr, err)
+	}
+	if fm := fi.Mode(); fm != 0700|os.ModeSocket {
+		t.Fatalf("file mode: want = %v, got = %v", 0700|os.ModeSocket, fm)
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	if int(st.Uid) != uid || int(st.Gid) != gid {
+		t.Fatalf("owner: want = %v:%v, got = %v:%v", uid, gid, st.Uid, st.Gid)
+	}
+}
+
+func TestClientRemovesStaleUnixSocket(t *testing.T) {
+	testDir := t.TempDir()
+	addr := filepath.Join(testDir, mysql)
+	// Leave a socket behind, as a process that crashed would.
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: addr, Net: "unix"})
+	if err != nil {
+		t.Fatalf("net.ListenUnix error: %v", err)
+	}
+	ln.SetUnlinkOnClose(false)
+	ln.Close()
+
+	in := &proxy.Config{
+		UnixSocket: testDir,
+		Instances:  []proxy.InstanceConnConfig{{Name: mysql}},
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		t.Fatalf("net.Dial error: %v", err)
+	}
+	conn.Close()
+}
+
+func TestClientFailsOnUnixSocketInUse(t *testing.T) {
+	testDir := t.TempDir()
+	ln, err := net.Listen("unix", filepath.Join(testDir, mysql))
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	defer ln.Close()
+
+	in := &proxy.Config{
+		UnixSocket: testDir,
+		Instances:  []proxy.InstanceConnConfig{{Name: mysql}},
+	}
+	if _, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil); err == nil {
+		t.Fatal("want error for a Unix socket in use, got nil")
+	}
+}
+
+func TestClientLocksUnixSocketDir(t *testing.T) {
+	testDir := t.TempDir()
+	newClient := func(inst string) (*proxy.Client, error) {
+		return proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, &proxy.Config{
+			UnixSocket:     testDir,
+			UnixSocketLock: true,
+			Instances:      []proxy.InstanceConnConfig{{Name: inst}},
+		}, nil)
+	}
+	c, err := newClient(mysql)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	if _, err := newClient(mysql2); err == nil {
+		t.Fatal("want error for a locked directory, got nil")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("c.Close error: %v", err)
+	}
+
+	c2, err := newClient(mysql2)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error after unlock: %v", err)
+	}
+	c2.Close()
+}
+
+func TestClientHandsOffListeners(t *testing.T) {
+	testDir := t.TempDir()
+	addr := filepath.Join(testDir, mysql)
+	added := filepath.Join(testDir, mysql2)
+	newConfig := func(lns map[string]net.Listener) *proxy.Config {
+		return &proxy.Config{
+			UnixSocket:         testDir,
+			UnixSocketLock:     true,
+			Instances:          []proxy.InstanceConnConfig{{Name: mysql}},
+			InheritedListeners: lns,
+		}
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, newConfig(nil), nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background(), func() {})
+	if _, err := c.AddInstance(context.Background(), proxy.InstanceConnConfig{Name: mysql2}); err != nil {
+		t.Fatalf("c.AddInstance error: %v", err)
+	}
+
+	// Only the configured instance is handed over, as the new process does
+	// not know about instances added at runtime.
+	lfs, err := c.ListenerFiles()
+	if err != nil {
+		t.Fatalf("c.ListenerFiles error: %v", err)
+	}
+	if len(lfs) != 1 || lfs[0].Name != mysql || lfs[0].Activated {
+		t.Fatalf("listener files: want one for %v, got = %v", mysql, lfs)
+	}
+	ln, err := net.FileListener(lfs[0].File)
+	lfs[0].File.Close()
+	if err != nil {
+		t.Fatalf("net.FileListener error: %v", err)
+	}
+	if err := c.UnlockUnixSocketDir(); err != nil {
+		t.Fatalf("c.UnlockUnixSocketDir error: %v", err)
+	}
+
+	// A listener for an instance the new process does not serve is closed.
+	unused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	defer unused.Close()
+	d := &fakeDialer{}
+	inherited := map[string]net.Listener{mysql: ln, pg: unused}
+	c2, err := proxy.NewClient(context.Background(), d, testLogger, newConfig(inherited), nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error for the new client: %v", err)
+	}
+	defer c2.Close()
+	go c2.Serve(context.Background(), func() {})
+	if _, err := unused.Accept(); err == nil {
+		t.Fatal("want the unused listener to be closed, got nil error")
+	}
+
+	c.StopAccepting()
+	if _, err := os.Stat(addr); err != nil {
+		t.Fatalf("want socket to remain after handing off, got: %v", err)
+	}
+	if _, err := os.Stat(added); !os.IsNotExist(err) {
+		t.Fatalf("want the added instance's socket to be removed, got: %v", err)
+	}
+	if _, err := c.AddInstance(context.Background(), proxy.InstanceConnConfig{Name: mysql2}); err == nil {
+		t.Fatal("want error adding an instance after handing off, got nil")
+	}
+
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		t.Fatalf("net.Dial error: %v", err)
+	}
+	defer conn.Close()
+	// Wait for the connection to be proxied by the new client.
+	for i := 0; i < 10 && d.dialAttempts() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := d.dialAttempts(); got != 1 {
+		t.Fatalf("dial attempts: want = 1, got = %v", got)
+	}
+}
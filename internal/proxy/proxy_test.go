@@ -25,10 +25,12 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/cloudsqlconn"
+	"cloud.google.com/go/cloudsqlconn/errtype"
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/log"
 	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/internal/proxy"
 )
@@ -360,6 +362,35 @@ func TestClientInitialization(t *testing.T) {
 				filepath.Join(testUnixSocketPathPg),
 			},
 		},
+		{
+			desc: "with a Unix socket name template",
+			in: &proxy.Config{
+				UnixSocket:             testDir,
+				UnixSocketNameTemplate: "{project}-{instance}",
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "proj:region:pg"},
+					{Name: "proj:region:mysql"},
+				},
+			},
+			wantUnixAddrs: []string{
+				filepath.Join(testDir, "proj-pg", ".s.PGSQL.5432"),
+				filepath.Join(testDir, "proj-mysql"),
+			},
+		},
+		{
+			desc: "with Unix socket ports",
+			in: &proxy.Config{
+				UnixSocket: testDir,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "proj:region:pg", UnixSocketPort: 5433},
+					{Name: "proj:region:mysql", UnixSocketPort: 3307},
+				},
+			},
+			wantUnixAddrs: []string{
+				filepath.Join(testDir, ".s.PGSQL.5433"),
+				filepath.Join(testDir, ".s.MYSQL.3307"),
+			},
+		},
 		{
 			desc: "with Unix socket and two instances, one invalid but skipped",
 			in: &proxy.Config{
@@ -878,6 +909,25 @@ func TestProxyInitializationWithFailedUnixSocket(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "with a Unix socket port for SQL Server",
+			in: &proxy.Config{
+				UnixSocket: testDir,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "proj:region:sqlserver", UnixSocketPort: 1433},
+				},
+			},
+		},
+		{
+			desc: "with two instances using the same Unix socket port",
+			in: &proxy.Config{
+				UnixSocket: testDir,
+				Instances: []proxy.InstanceConnConfig{
+					{Name: "proj:region:pg", UnixSocketPort: 6543},
+					{Name: "proj:region:pg2", UnixSocketPort: 6543},
+				},
+			},
+		},
 		{
 			desc: "without TCP port or unix socket for non functional instance",
 			in: &proxy.Config{
@@ -940,3 +990,734 @@ func TestProxyMultiInstances(t *testing.T) {
 		})
 	}
 }
+
+func TestClientAddRemoveInstance(t *testing.T) {
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg", Port: 24018},
+		},
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- c.Serve(context.Background(), func() {}) }()
+
+	addr, err := c.AddInstance(context.Background(), proxy.InstanceConnConfig{
+		Name: "proj:region:mysql", Port: 24019,
+	})
+	if err != nil {
+		t.Fatalf("AddInstance error: %v", err)
+	}
+	if got, want := addr.String(), "127.0.0.1:24019"; got != want {
+		t.Fatalf("AddInstance address, want = %v, got = %v", want, got)
+	}
+	if _, err := c.AddInstance(context.Background(), proxy.InstanceConnConfig{
+		Name: "proj:region:mysql", Port: 24020,
+	}); err == nil {
+		t.Fatal("AddInstance should fail for a mounted instance, got nil")
+	}
+	if got := len(c.Instances()); got != 2 {
+		t.Fatalf("want 2 instances, got = %v", got)
+	}
+
+	// An open connection survives removal of its instance.
+	conn := tryTCPDial(t, "127.0.0.1:24019")
+	defer conn.Close()
+
+	if err := c.RemoveInstance("proj:region:mysql"); err != nil {
+		t.Fatalf("RemoveInstance error: %v", err)
+	}
+	if err := c.RemoveInstance("proj:region:mysql"); err == nil {
+		t.Fatal("RemoveInstance should fail for an unmounted instance, got nil")
+	}
+	if _, err := net.Dial("tcp", "127.0.0.1:24019"); err == nil {
+		t.Fatal("want dial to removed instance to fail, got nil")
+	}
+	if got, _ := c.ConnCount(); got != 1 {
+		t.Fatalf("want 1 open connection after removal, got = %v", got)
+	}
+	// The remaining instance is still serving.
+	conn2 := tryTCPDial(t, "127.0.0.1:24018")
+	defer conn2.Close()
+
+	select {
+	case err := <-serveErr:
+		t.Fatalf("Serve should not return after RemoveInstance, got = %v", err)
+	default:
+	}
+}
+
+func TestClientDrainRefusesNewConnections(t *testing.T) {
+	d := &fakeDialer{}
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg", Port: 24035},
+			{Name: "proj:region:mysql", Port: 24036},
+		},
+	}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background(), func() {})
+
+	// An open connection survives the drain.
+	open := tryTCPDial(t, "127.0.0.1:24035")
+	defer open.Close()
+
+	c.Drain(false, "proj:region:pg")
+	if !c.Draining() {
+		t.Fatal("want Draining to report true after Drain")
+	}
+	// The socket is still mounted, but new connections are closed.
+	conn := tryTCPDial(t, "127.0.0.1:24035")
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("want connection to a draining instance to be closed, got = %v", err)
+	}
+	// The other instance still accepts connections.
+	conn2 := tryTCPDial(t, "127.0.0.1:24036")
+	defer conn2.Close()
+	for i := 0; i < 10 && d.dialAttempts() < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := d.dialAttempts(); got != 2 {
+		t.Fatalf("dial attempts: want = 2, got = %v", got)
+	}
+
+	for _, i := range c.Instances() {
+		if want := i.Name == "proj:region:pg"; i.Draining != want {
+			t.Fatalf("%v draining: want = %v, got = %v", i.Name, want, i.Draining)
+		}
+	}
+	if got, _ := c.ConnCount(); got != 2 {
+		t.Fatalf("want 2 open connections, got = %v", got)
+	}
+}
+
+func TestClientLimitsInstanceConnections(t *testing.T) {
+	d := &fakeDialer{}
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg", Port: 24027, MaxConnections: 1},
+			{Name: "proj:region:mysql", Port: 24028},
+		},
+	}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background(), func() {})
+
+	conn1 := tryTCPDial(t, "127.0.0.1:24027")
+	defer conn1.Close()
+	conn2 := tryTCPDial(t, "127.0.0.1:24027")
+	defer conn2.Close()
+	// The other instance has no limit.
+	conn3 := tryTCPDial(t, "127.0.0.1:24028")
+	defer conn3.Close()
+
+	conn2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn2.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("want connection beyond instance limit to be closed, got = %v", err)
+	}
+	if got := d.dialAttempts(); got != 2 {
+		t.Fatalf("dial attempts did not match expected, want = 2, got = %v", got)
+	}
+}
+
+func TestClientQueuesConnections(t *testing.T) {
+	d := &fakeDialer{}
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Port: 24029,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg"},
+		},
+		MaxConnections:   1,
+		ConnQueueTimeout: 10 * time.Second,
+	}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background(), func() {})
+
+	conn1 := tryTCPDial(t, "127.0.0.1:24029")
+	conn2 := tryTCPDial(t, "127.0.0.1:24029")
+	defer conn2.Close()
+
+	if queued, _ := c.ConnQueue(); queued != 1 {
+		t.Fatalf("want 1 queued connection, got = %v", queued)
+	}
+	if got := d.dialAttempts(); got != 1 {
+		t.Fatalf("dial attempts did not match expected, want = 1, got = %v", got)
+	}
+
+	// Closing the first connection admits the queued connection.
+	conn1.Close()
+	for i := 0; i < 10; i++ {
+		if d.dialAttempts() == 2 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := d.dialAttempts(); got != 2 {
+		t.Fatalf("dial attempts did not match expected, want = 2, got = %v", got)
+	}
+	queued, wait := c.ConnQueue()
+	if queued != 0 {
+		t.Fatalf("want 0 queued connections, got = %v", queued)
+	}
+	if wait == 0 {
+		t.Fatal("want a non-zero queue wait, got = 0")
+	}
+}
+
+// flakyDialer fails the first failures dial attempts with err.
+type flakyDialer struct {
+	fakeDialer
+	failures int
+	err      error
+}
+
+func (f *flakyDialer) Dial(ctx context.Context, inst string, opts ...cloudsqlconn.DialOption) (net.Conn, error) {
+	f.mu.Lock()
+	if f.dialCount < f.failures {
+		f.dialCount++
+		f.mu.Unlock()
+		return nil, f.err
+	}
+	f.mu.Unlock()
+	return f.fakeDialer.Dial(ctx, inst, opts...)
+}
+
+func TestClientRetriesDial(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		port      int
+		err       error
+		wantDials int
+	}{
+		{
+			desc:      "temporary errors are retried",
+			port:      24030,
+			err:       errors.New("connection reset"),
+			wantDials: 3,
+		},
+		{
+			desc:      "permanent errors are not retried",
+			port:      24031,
+			err:       errtype.NewConfigError("bad config", "proj:region:pg"),
+			wantDials: 1,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			d := &flakyDialer{failures: 2, err: tc.err}
+			in := &proxy.Config{
+				Addr: "127.0.0.1",
+				Port: tc.port,
+				Instances: []proxy.InstanceConnConfig{{
+					Name:             "proj:region:pg",
+					DialRetries:      pointer(3),
+					DialRetryBackoff: pointer(time.Millisecond),
+				}},
+			}
+			c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+			if err != nil {
+				t.Fatalf("proxy.NewClient error: %v", err)
+			}
+			defer c.Close()
+			go c.Serve(context.Background(), func() {})
+
+			conn := tryTCPDial(t, fmt.Sprintf("127.0.0.1:%d", tc.port))
+			defer conn.Close()
+
+			var got int
+			for i := 0; i < 10; i++ {
+				if got = d.dialAttempts(); got >= tc.wantDials {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			if got != tc.wantDials {
+				t.Fatalf("dial attempts, want = %v, got = %v", tc.wantDials, got)
+			}
+		})
+	}
+}
+
+func TestClientStopsRetryingDialOnClose(t *testing.T) {
+	d := &flakyDialer{failures: 100, err: errors.New("connection reset")}
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Port: 24039,
+		Instances: []proxy.InstanceConnConfig{{
+			Name:             "proj:region:pg",
+			DialRetries:      pointer(100),
+			DialRetryBackoff: pointer(100 * time.Millisecond),
+		}},
+	}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	go c.Serve(context.Background(), func() {})
+
+	conn := tryTCPDial(t, "127.0.0.1:24039")
+	defer conn.Close()
+	for i := 0; i < 10 && d.dialAttempts() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("c.Close error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	want := d.dialAttempts()
+
+	time.Sleep(time.Second)
+	if got := d.dialAttempts(); got != want {
+		t.Fatalf("dial attempts after Close, want = %v, got = %v", want, got)
+	}
+}
+
+// flakyVersionDialer fails the first failures engine version lookups with
+// err.
+type flakyVersionDialer struct {
+	fakeDialer
+	failures int
+	err      error
+}
+
+func (f *flakyVersionDialer) EngineVersion(ctx context.Context, inst string) (string, error) {
+	f.mu.Lock()
+	if f.engineVersionCount < f.failures {
+		f.engineVersionCount++
+		f.mu.Unlock()
+		return "", f.err
+	}
+	f.mu.Unlock()
+	return f.fakeDialer.EngineVersion(ctx, inst)
+}
+
+func TestClientRetriesSkippedInstances(t *testing.T) {
+	tcs := []struct {
+		desc      string
+		err       error
+		wantState string
+	}{
+		{
+			desc:      "temporary errors are retried",
+			err:       errors.New("service unavailable"),
+			wantState: proxy.InstanceMounted,
+		},
+		{
+			desc:      "permanent errors are not retried",
+			err:       errtype.NewConfigError("bad config", "proj:region:pg"),
+			wantState: proxy.InstanceFailed,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			testDir, cleanup := createTempDir(t)
+			defer cleanup()
+			// Fail the startup warmup and the first mount attempt.
+			d := &flakyVersionDialer{failures: 2, err: tc.err}
+			in := &proxy.Config{
+				UnixSocket:               testDir,
+				Instances:                []proxy.InstanceConnConfig{{Name: "proj:region:pg"}},
+				SkipFailedInstanceConfig: true,
+			}
+			c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+			if err != nil {
+				t.Fatalf("proxy.NewClient error: %v", err)
+			}
+			defer c.Close()
+
+			infos := c.Instances()
+			if len(infos) != 1 || infos[0].State == proxy.InstanceMounted {
+				t.Fatalf("want one instance that is not mounted, got = %+v", infos)
+			}
+			go c.Serve(context.Background(), func() {})
+
+			var got proxy.InstanceInfo
+			for i := 0; i < 30; i++ {
+				got = c.Instances()[0]
+				if got.State == tc.wantState {
+					break
+				}
+				time.Sleep(100 * time.Millisecond)
+			}
+			if got.State != tc.wantState {
+				t.Fatalf("state: want = %v, got = %+v", tc.wantState, got)
+			}
+			if tc.wantState == proxy.InstanceMounted {
+				conn, err := net.Dial("unix", got.Addr)
+				if err != nil {
+					t.Fatalf("net.Dial error: %v", err)
+				}
+				conn.Close()
+			}
+		})
+	}
+}
+
+// slowVersionDialer fails engine version lookups until blocking is set, then
+// holds them until release is closed, as an unresponsive Admin API would.
+type slowVersionDialer struct {
+	fakeDialer
+	blocking atomic.Bool
+	blocked  chan struct{}
+	release  chan struct{}
+}
+
+func (s *slowVersionDialer) EngineVersion(ctx context.Context, inst string) (string, error) {
+	if !s.blocking.Load() {
+		return "", errors.New("service unavailable")
+	}
+	select {
+	case s.blocked <- struct{}{}:
+	default:
+	}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return s.fakeDialer.EngineVersion(ctx, inst)
+}
+
+func TestClientInstancesNotBlockedByPendingMount(t *testing.T) {
+	d := &slowVersionDialer{
+		blocked: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	in := &proxy.Config{
+		Addr:                     "127.0.0.1",
+		UnixSocket:               t.TempDir(),
+		Instances:                []proxy.InstanceConnConfig{{Name: "proj:region:pg"}},
+		SkipFailedInstanceConfig: true,
+	}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	defer close(d.release)
+	d.blocking.Store(true)
+	go c.Serve(context.Background(), func() {})
+
+	select {
+	case <-d.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the pending instance to be mounted again")
+	}
+	done := make(chan []proxy.InstanceInfo)
+	go func() { done <- c.Instances() }()
+	select {
+	case infos := <-done:
+		if len(infos) != 1 || infos[0].State != proxy.InstancePending {
+			t.Fatalf("want one pending instance, got = %+v", infos)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("c.Instances blocked while a pending instance was mounted")
+	}
+
+	added := make(chan error)
+	go func() {
+		_, err := c.AddInstance(context.Background(), proxy.InstanceConnConfig{
+			Name: "proj:region:other", Port: 24037,
+		})
+		added <- err
+	}()
+	select {
+	case err := <-added:
+		if err != nil {
+			t.Fatalf("c.AddInstance error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("c.AddInstance blocked while a pending instance was mounted")
+	}
+}
+
+func TestClientCloseStopsPendingMounts(t *testing.T) {
+	d := &slowVersionDialer{
+		blocked: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	in := &proxy.Config{
+		UnixSocket:               t.TempDir(),
+		Instances:                []proxy.InstanceConnConfig{{Name: "proj:region:pg"}},
+		SkipFailedInstanceConfig: true,
+	}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	d.blocking.Store(true)
+	go c.Serve(context.Background(), func() {})
+
+	select {
+	case <-d.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the pending instance to be mounted again")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("c.Close error: %v", err)
+	}
+	// Let the mount finish after Close has returned.
+	close(d.release)
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		for _, info := range c.Instances() {
+			if info.State == proxy.InstanceMounted {
+				t.Fatalf("want no mounted instances after Close, got = %+v", info)
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientMountsWithoutEngineVersionLookup(t *testing.T) {
+	tcs := []struct {
+		desc  string
+		inst  proxy.InstanceConnConfig
+		cache string
+	}{
+		{
+			desc: "engine query param",
+			inst: proxy.InstanceConnConfig{Name: "proj:region:inst", Engine: "POSTGRES"},
+		},
+		{
+			desc:  "engine version cache",
+			inst:  proxy.InstanceConnConfig{Name: "proj:region:inst"},
+			cache: `{"proj:region:inst": "POSTGRES_14"}`,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			testDir, cleanup := createTempDir(t)
+			defer cleanup()
+			in := &proxy.Config{
+				UnixSocket: testDir,
+				Instances:  []proxy.InstanceConnConfig{tc.inst},
+			}
+			if tc.cache != "" {
+				in.EngineVersionCache = filepath.Join(testDir, "engines.json")
+				if err := os.WriteFile(in.EngineVersionCache, []byte(tc.cache), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Every engine version lookup fails, as if the Admin API were
+			// unreachable.
+			d := &flakyVersionDialer{failures: 100, err: errors.New("service unavailable")}
+			c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+			if err != nil {
+				t.Fatalf("proxy.NewClient error: %v", err)
+			}
+			defer c.Close()
+
+			want := filepath.Join(testDir, "proj:region:inst", ".s.PGSQL.5432")
+			if got := c.Instances()[0].Addr; got != want {
+				t.Fatalf("addr: want = %v, got = %v", want, got)
+			}
+		})
+	}
+}
+
+func TestClientWritesEngineVersionCache(t *testing.T) {
+	testDir, cleanup := createTempDir(t)
+	defer cleanup()
+	cache := filepath.Join(testDir, "engines.json")
+	in := &proxy.Config{
+		Addr:               "127.0.0.1",
+		Instances:          []proxy.InstanceConnConfig{{Name: "proj:region:mysql"}},
+		EngineVersionCache: cache,
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+
+	b, err := os.ReadFile(cache)
+	if err != nil {
+		t.Fatalf("os.ReadFile error: %v", err)
+	}
+	if !strings.Contains(string(b), `"proj:region:mysql": "MYSQL_8_0"`) {
+		t.Fatalf("want cached MySQL version, got = %s", b)
+	}
+}
+
+func TestClientUsesActivatedListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	unused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error: %v", err)
+	}
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg"},
+		},
+		Listeners: map[string]net.Listener{
+			"proj:region:pg":    ln,
+			"proj:region:other": unused,
+		},
+	}
+	d := &fakeDialer{}
+	c, err := proxy.NewClient(context.Background(), d, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	go c.Serve(context.Background(), func() {})
+
+	if got, want := c.Instances()[0].Addr, ln.Addr().String(); got != want {
+		t.Fatalf("addr: want = %v, got = %v", want, got)
+	}
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("net.Dial error: %v", err)
+	}
+	defer conn.Close()
+	// Wait for the connection to be proxied.
+	for i := 0; i < 10 && d.dialAttempts() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := d.dialAttempts(); got != 1 {
+		t.Fatalf("dial attempts: want = 1, got = %v", got)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("c.Close error: %v", err)
+	}
+	// Closing the client also closes listeners no instance used.
+	if _, err := unused.Accept(); err == nil {
+		t.Fatal("want unused listener to be closed")
+	}
+}
+
+func TestClientClosesConnectionsAfterTimeout(t *testing.T) {
+	tcs := []struct {
+		desc string
+		port int
+		inst proxy.InstanceConnConfig
+	}{
+		{
+			desc: "idle timeout",
+			port: 24032,
+			inst: proxy.InstanceConnConfig{
+				Name:        "proj:region:pg",
+				IdleTimeout: pointer(100 * time.Millisecond),
+			},
+		},
+		{
+			desc: "max lifetime",
+			port: 24033,
+			inst: proxy.InstanceConnConfig{
+				Name:            "proj:region:pg",
+				MaxConnLifetime: pointer(100 * time.Millisecond),
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.desc, func(t *testing.T) {
+			in := &proxy.Config{
+				Addr:      "127.0.0.1",
+				Port:      tc.port,
+				Instances: []proxy.InstanceConnConfig{tc.inst},
+			}
+			c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil)
+			if err != nil {
+				t.Fatalf("proxy.NewClient error: %v", err)
+			}
+			defer c.Close()
+			go c.Serve(context.Background(), func() {})
+
+			conn := tryTCPDial(t, fmt.Sprintf("127.0.0.1:%d", tc.port))
+			defer conn.Close()
+
+			if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatalf("SetReadDeadline error: %v", err)
+			}
+			_, err = conn.Read(make([]byte, 1))
+			if err != io.EOF {
+				t.Fatalf("want connection to be closed with EOF, got = %v", err)
+			}
+		})
+	}
+}
+
+func TestClientTracksConnections(t *testing.T) {
+	in := &proxy.Config{
+		Addr: "127.0.0.1",
+		Port: 24034,
+		Instances: []proxy.InstanceConnConfig{
+			{Name: "proj:region:pg"},
+		},
+	}
+	c, err := proxy.NewClient(context.Background(), &fakeDialer{}, testLogger, in, nil)
+	if err != nil {
+		t.Fatalf("proxy.NewClient error: %v", err)
+	}
+	defer c.Close()
+	go c.Serve(context.Background(), func() {})
+
+	conn := tryTCPDial(t, "127.0.0.1:24034")
+	defer conn.Close()
+
+	var conns []proxy.ConnInfo
+	for i := 0; i < 10; i++ {
+		if conns = c.Connections(); len(conns) == 1 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(conns) != 1 {
+		t.Fatalf("want 1 connection, got = %v", conns)
+	}
+	got := conns[0]
+	if got.Instance != "proj:region:pg" {
+		t.Fatalf("want instance = proj:region:pg, got = %v", got.Instance)
+	}
+	if got.ClientAddr != conn.LocalAddr().String() {
+		t.Fatalf("want client address = %v, got = %v", conn.LocalAddr(), got.ClientAddr)
+	}
+
+	if err := c.CloseConnection(got.ID); err != nil {
+		t.Fatalf("CloseConnection error: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline error: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("want connection to be closed with EOF, got = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if conns = c.Connections(); len(conns) == 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if len(conns) != 0 {
+		t.Fatalf("want no connections, got = %v", conns)
+	}
+	if err := c.CloseConnection(got.ID); err == nil {
+		t.Fatal("want an error closing an unknown connection, got nil")
+	}
+}
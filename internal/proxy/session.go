@@ -0,0 +1,512 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"sync"
+)
+
+// sessionTracker follows the wire protocol of a proxied connection to tell
+// whether its database session is idle outside of a transaction. Only the
+// unencrypted protocol can be followed. Once a client negotiates TLS with the
+// database, the session is never reported as idle.
+type sessionTracker interface {
+	// clientWriter observes the bytes sent by the client.
+	clientWriter() io.Writer
+	// serverWriter observes the bytes sent by the instance.
+	serverWriter() io.Writer
+	// idle reports whether no request is in progress and no transaction is
+	// open.
+	idle() bool
+}
+
+// newSessionTracker returns a sessionTracker for connections to the instance
+// of s, or nil if transaction-aware shutdown is disabled or the database
+// engine is not supported.
+func (c *Client) newSessionTracker(s *socketMount) sessionTracker {
+	if !c.conf.TransactionAwareShutdown {
+		return nil
+	}
+	version, err := c.mountEngineVersion(s)
+	if err != nil {
+		s.logger.Debugf("could not resolve instance version for transaction-aware shutdown: %v", err)
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(version, "POSTGRES"):
+		return newPostgresSession()
+	case strings.HasPrefix(version, "MYSQL"):
+		return newMySQLSession()
+	default:
+		return nil
+	}
+}
+
+// mountEngineVersion returns the database engine version of the socket
+// mount's instance, which is only resolved once per mount. The lookup may
+// call the Admin API for every new connection until it succeeds, so it is
+// bounded by the dial timeout.
+func (c *Client) mountEngineVersion(s *socketMount) (string, error) {
+	s.engineMu.Lock()
+	defer s.engineMu.Unlock()
+	if s.engine != "" {
+		return s.engine, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.dialSettings.timeout)
+	defer cancel()
+	v, err := c.engineVersion(ctx, c.conf, s.cfg)
+	if err != nil {
+		return "", err
+	}
+	s.engine = v
+	return v, nil
+}
+
+// writerFunc adapts a function to an io.Writer that never fails.
+type writerFunc func(p []byte)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	f(p)
+	return len(p), nil
+}
+
+// Postgres protocol codes sent by the client in untyped messages.
+// See https://www.postgresql.org/docs/current/protocol-message-formats.html
+const (
+	pgCancelRequest = 80877102
+	pgSSLRequest    = 80877103
+	pgGSSENCRequest = 80877104
+)
+
+// postgresSession follows the Postgres protocol. A session is idle once the
+// server has answered every query or sync with ReadyForQuery, and the last
+// ReadyForQuery reported an idle transaction status.
+type postgresSession struct {
+	mu     sync.Mutex
+	client pgStream
+	server pgStream
+	// encReply is set when the client asked for TLS or GSSAPI encryption
+	// and the server's single byte reply is next.
+	encReply bool
+	// pending is the number of client messages that the server has not yet
+	// answered with ReadyForQuery.
+	pending int
+	// unsynced is set when the client has sent extended query messages that
+	// are not yet followed by a Sync.
+	unsynced bool
+	// status is the transaction status of the last ReadyForQuery: 'I' for
+	// idle, 'T' in a transaction, or 'E' in a failed transaction.
+	status byte
+	// opaque is set once the protocol can no longer be followed.
+	opaque bool
+}
+
+func newPostgresSession() *postgresSession {
+	return &postgresSession{server: pgStream{typed: true}}
+}
+
+func (s *postgresSession) clientWriter() io.Writer {
+	return writerFunc(func(p []byte) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.opaque {
+			return
+		}
+		if !s.client.feed(p, s.clientMessage) {
+			s.opaque = true
+		}
+	})
+}
+
+func (s *postgresSession) serverWriter() io.Writer {
+	return writerFunc(func(p []byte) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.opaque || len(p) == 0 {
+			return
+		}
+		if s.encReply {
+			s.encReply = false
+			if p[0] != 'N' {
+				// The server accepted encryption.
+				s.opaque = true
+				return
+			}
+			p = p[1:]
+		}
+		if !s.server.feed(p, s.serverMessage) {
+			s.opaque = true
+		}
+	})
+}
+
+func (s *postgresSession) clientMessage(hdr []byte) {
+	if !s.client.typed {
+		switch binary.BigEndian.Uint32(hdr[4:8]) {
+		case pgSSLRequest, pgGSSENCRequest:
+			s.encReply = true
+		case pgCancelRequest:
+		default:
+			// The startup message is answered with ReadyForQuery once
+			// authentication completes.
+			s.client.typed = true
+			s.pending++
+		}
+		return
+	}
+	switch hdr[0] {
+	case 'Q', 'F':
+		s.pending++
+	case 'S':
+		s.pending++
+		s.unsynced = false
+	case 'P', 'B', 'D', 'E', 'C', 'H':
+		s.unsynced = true
+	}
+}
+
+func (s *postgresSession) serverMessage(hdr []byte) {
+	if hdr[0] != 'Z' || len(hdr) < 6 {
+		return
+	}
+	s.status = hdr[5]
+	if s.pending > 0 {
+		s.pending--
+	}
+}
+
+func (s *postgresSession) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.opaque && s.pending == 0 && !s.unsynced && s.status == 'I'
+}
+
+// pgStream splits a stream of Postgres messages written in arbitrary chunks.
+type pgStream struct {
+	// typed is set once the stream is past the untyped startup messages.
+	typed bool
+	// hdr buffers the start of the current message.
+	hdr []byte
+	// skip is the number of bytes of the current message left to skip.
+	skip int
+}
+
+// headerLen returns how many bytes of the current message are needed before
+// it can be handled: the type, length, and first body byte of a typed
+// message, or the length and code of an untyped message.
+func (s *pgStream) headerLen() int {
+	if !s.typed {
+		return 8
+	}
+	if len(s.hdr) >= 5 && binary.BigEndian.Uint32(s.hdr[1:5]) <= 4 {
+		return 5
+	}
+	return 6
+}
+
+// feed splits p into messages and calls msg with the start of each message.
+// It reports false if the stream is malformed.
+func (s *pgStream) feed(p []byte, msg func(hdr []byte)) bool {
+	for len(p) > 0 {
+		if s.skip > 0 {
+			n := min(s.skip, len(p))
+			s.skip -= n
+			p = p[n:]
+			continue
+		}
+		n := min(s.headerLen()-len(s.hdr), len(p))
+		s.hdr = append(s.hdr, p[:n]...)
+		p = p[n:]
+		if len(s.hdr) < s.headerLen() {
+			continue
+		}
+		var total int
+		if s.typed {
+			l := binary.BigEndian.Uint32(s.hdr[1:5])
+			if l < 4 {
+				return false
+			}
+			total = 1 + int(l)
+		} else {
+			l := binary.BigEndian.Uint32(s.hdr[0:4])
+			if l < 8 {
+				return false
+			}
+			total = int(l)
+		}
+		s.skip = total - len(s.hdr)
+		msg(s.hdr)
+		s.hdr = s.hdr[:0]
+	}
+	return true
+}
+
+// MySQL protocol constants.
+// See https://dev.mysql.com/doc/dev/mysql-server/latest/PAGE_PROTOCOL.html
+const (
+	mysqlClientSSL          = 0x00000800
+	mysqlClientDeprecateEOF = 0x01000000
+
+	mysqlStatusInTrans     = 0x0001
+	mysqlStatusMoreResults = 0x0008
+
+	mysqlComInitDB          = 0x02
+	mysqlComQuery           = 0x03
+	mysqlComPing            = 0x0e
+	mysqlComStmtExecute     = 0x17
+	mysqlComResetConnection = 0x1f
+
+	// mysqlMaxPayload is the payload length of a packet that is continued
+	// in the next packet.
+	mysqlMaxPayload = 0xffffff
+)
+
+// mysqlSession follows the MySQL protocol. A session is idle once the server
+// has completed the response to the last command, and the server status
+// flags of that response report no open transaction. Responses to commands
+// other than queries, statement executions, pings, and resets are not
+// followed, so the session is not idle until a later response is complete.
+type mysqlSession struct {
+	mu     sync.Mutex
+	client mysqlStream
+	server mysqlStream
+	// authenticated is set once the server accepts the handshake response.
+	authenticated bool
+	// deprecateEOF is set when result sets end with an OK packet instead of
+	// EOF packets.
+	deprecateEOF bool
+	// pending is set while the response to a command is in progress.
+	pending bool
+	// untracked is set when the response to a command cannot be followed.
+	untracked bool
+	// first is set when the next server packet starts a response.
+	first bool
+	// terminators is the number of EOF or OK packets left in a result set.
+	terminators int
+	// status holds the server status flags of the last completed response.
+	status uint16
+	// opaque is set once the protocol can no longer be followed.
+	opaque bool
+}
+
+func newMySQLSession() *mysqlSession {
+	return &mysqlSession{}
+}
+
+func (s *mysqlSession) clientWriter() io.Writer {
+	return writerFunc(func(p []byte) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.opaque {
+			s.client.feed(p, s.clientPacket)
+		}
+	})
+}
+
+func (s *mysqlSession) serverWriter() io.Writer {
+	return writerFunc(func(p []byte) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.opaque {
+			s.server.feed(p, s.serverPacket)
+		}
+	})
+}
+
+func (s *mysqlSession) clientPacket(seq byte, length int, payload []byte) {
+	if !s.authenticated {
+		if seq != 1 || len(payload) < 4 {
+			return
+		}
+		// The handshake response starts with the client capabilities.
+		flags := binary.LittleEndian.Uint32(payload)
+		if flags&mysqlClientSSL != 0 && length == 32 {
+			// The client sent an SSL request and starts TLS next.
+			s.opaque = true
+			return
+		}
+		s.deprecateEOF = flags&mysqlClientDeprecateEOF != 0
+		return
+	}
+	if seq != 0 || len(payload) == 0 {
+		// e.g., the contents of a LOCAL INFILE request
+		return
+	}
+	s.pending, s.first, s.terminators = true, true, 0
+	switch payload[0] {
+	case mysqlComQuery, mysqlComStmtExecute, mysqlComPing, mysqlComInitDB, mysqlComResetConnection:
+		s.untracked = false
+	default:
+		s.untracked = true
+	}
+}
+
+func (s *mysqlSession) serverPacket(_ byte, length int, payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	if !s.authenticated {
+		if payload[0] == 0x00 {
+			s.authenticated = true
+			s.status = mysqlOKStatus(payload)
+		}
+		return
+	}
+	if !s.pending || s.untracked {
+		return
+	}
+	if s.first {
+		s.first = false
+		switch payload[0] {
+		case 0x00:
+			s.complete(mysqlOKStatus(payload))
+		case 0xff:
+			s.pending = false
+		case 0xfb:
+			// A LOCAL INFILE request, answered with an OK or ERR packet
+			// once the client sends the file.
+			s.first = true
+		default:
+			// A result set starts with the column count.
+			s.terminators = 2
+			if s.deprecateEOF {
+				s.terminators = 1
+			}
+		}
+		return
+	}
+	switch {
+	case payload[0] == 0xff:
+		s.pending = false
+	case payload[0] == 0xfe && length < mysqlMaxPayload:
+		s.terminators--
+		if s.terminators > 0 {
+			return
+		}
+		if s.deprecateEOF {
+			s.complete(mysqlOKStatus(payload))
+			return
+		}
+		// An EOF packet: header, warnings, and status flags.
+		if len(payload) < 5 {
+			s.complete(mysqlStatusInTrans)
+			return
+		}
+		s.complete(binary.LittleEndian.Uint16(payload[3:5]))
+	}
+}
+
+// complete records the status of a finished response.
+func (s *mysqlSession) complete(status uint16) {
+	s.status = status
+	if status&mysqlStatusMoreResults != 0 {
+		s.first = true
+		return
+	}
+	s.pending = false
+}
+
+func (s *mysqlSession) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.opaque && s.authenticated && !s.pending && !s.untracked &&
+		s.status&mysqlStatusInTrans == 0
+}
+
+// mysqlOKStatus returns the status flags of an OK packet. If the packet is
+// malformed, the status reports an open transaction.
+func mysqlOKStatus(payload []byte) uint16 {
+	// Skip the header, affected rows, and last insert ID.
+	p := payload[1:]
+	for i := 0; i < 2; i++ {
+		n := mysqlLenEncSize(p)
+		if n == 0 || len(p) < n {
+			return mysqlStatusInTrans
+		}
+		p = p[n:]
+	}
+	if len(p) < 2 {
+		return mysqlStatusInTrans
+	}
+	return binary.LittleEndian.Uint16(p)
+}
+
+// mysqlLenEncSize returns the size of the length-encoded integer at the start
+// of p, or zero if p is empty or does not start with one.
+func mysqlLenEncSize(p []byte) int {
+	if len(p) == 0 {
+		return 0
+	}
+	switch {
+	case p[0] < 0xfb:
+		return 1
+	case p[0] == 0xfc:
+		return 3
+	case p[0] == 0xfd:
+		return 4
+	case p[0] == 0xfe:
+		return 9
+	default:
+		return 0
+	}
+}
+
+// mysqlPeekLen is the number of payload bytes buffered for each packet,
+// enough for the status flags of an OK packet.
+const mysqlPeekLen = 32
+
+// mysqlStream splits a stream of MySQL packets written in arbitrary chunks.
+type mysqlStream struct {
+	// hdr buffers the header and the start of the payload of the current
+	// packet.
+	hdr []byte
+	// skip is the number of bytes of the current packet left to skip.
+	skip int
+}
+
+// feed splits p into packets and calls pkt with the sequence ID, payload
+// length, and start of the payload of each packet.
+func (s *mysqlStream) feed(p []byte, pkt func(seq byte, length int, payload []byte)) {
+	for len(p) > 0 {
+		if s.skip > 0 {
+			n := min(s.skip, len(p))
+			s.skip -= n
+			p = p[n:]
+			continue
+		}
+		want := 4
+		if len(s.hdr) >= 4 {
+			want += min(s.payloadLen(), mysqlPeekLen)
+		}
+		n := min(want-len(s.hdr), len(p))
+		s.hdr = append(s.hdr, p[:n]...)
+		p = p[n:]
+		if len(s.hdr) < 4 || len(s.hdr) < 4+min(s.payloadLen(), mysqlPeekLen) {
+			continue
+		}
+		length := s.payloadLen()
+		s.skip = 4 + length - len(s.hdr)
+		pkt(s.hdr[3], length, s.hdr[4:])
+		s.hdr = s.hdr[:0]
+	}
+}
+
+func (s *mysqlStream) payloadLen() int {
+	return int(s.hdr[0]) | int(s.hdr[1])<<8 | int(s.hdr[2])<<16
+}
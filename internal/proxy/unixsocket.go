@@ -0,0 +1,167 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/GoogleCloudPlatform/cloud-sql-proxy/v2/cloudsql"
+)
+
+const (
+	// defaultUnixSocketMode allows access to Unix sockets for user, group,
+	// and other.
+	defaultUnixSocketMode os.FileMode = 0777
+
+	// unixSocketLockFile is the name of the lock file in a locked Unix
+	// socket directory.
+	unixSocketLockFile = "cloud-sql-proxy.lock"
+
+	// staleSocketProbeTimeout is how long to wait when connecting to an
+	// existing Unix socket to check whether it is still in use.
+	staleSocketProbeTimeout = time.Second
+)
+
+// removeStaleUnixSocket removes a Unix socket at address that was left
+// behind by a process that exited without closing it. If a process still
+// accepts connections on the socket, it returns an error. Anything else at
+// the address is left for listening to report.
+func removeStaleUnixSocket(address string, l cloudsql.Logger) error {
+	fi, err := os.Lstat(address)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", address, staleSocketProbeTimeout)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %v is in use by another process", address)
+	}
+	// Only a refused connection shows that nothing is listening. Other
+	// errors, e.g., permission denied, leave the socket alone.
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	if err := os.Remove(address); err != nil {
+		return fmt.Errorf("failed to remove stale unix socket %v: %w", address, err)
+	}
+	l.Infof("Removed stale Unix socket %v", address)
+	return nil
+}
+
+// unixSocketPerms is the mode and ownership of Unix sockets. The zero value
+// allows access for user, group, and other and leaves ownership unchanged.
+type unixSocketPerms struct {
+	// mode is the file mode of the socket, or zero for the default.
+	mode os.FileMode
+	// uid and gid are the owner and group of the socket, or nil to leave
+	// them unchanged.
+	uid, gid *int
+}
+
+// newUnixSocketPerms resolves the Unix socket permissions from the
+// configuration.
+func newUnixSocketPerms(c *Config) unixSocketPerms {
+	return unixSocketPerms{
+		mode: c.UnixSocketMode,
+		uid:  c.UnixSocketUID,
+		gid:  c.UnixSocketGID,
+	}
+}
+
+// apply sets the mode and ownership of the Unix socket at address. With the
+// default settings, this is best effort and never fails.
+func (p unixSocketPerms) apply(address string) error {
+	if p.mode == 0 {
+		// Best effort. If this call fails, group and other won't have write
+		// access.
+		_ = os.Chmod(address, defaultUnixSocketMode)
+	} else if err := os.Chmod(address, p.mode); err != nil {
+		return fmt.Errorf("failed to set mode of Unix socket: %w", err)
+	}
+	if p.uid == nil && p.gid == nil {
+		return nil
+	}
+	uid, gid := -1, -1
+	if p.uid != nil {
+		uid = *p.uid
+	}
+	if p.gid != nil {
+		gid = *p.gid
+	}
+	if err := os.Chown(address, uid, gid); err != nil {
+		return fmt.Errorf("failed to set owner of Unix socket: %w", err)
+	}
+	return nil
+}
+
+// unixSocketNamePlaceholder matches a placeholder in a Unix socket name
+// template.
+var unixSocketNamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// UnixSocketName returns the name of the Unix socket for the instance using
+// the template, where {project}, {region}, and {instance} are replaced with
+// the parts of the instance connection name and {name} with the whole name.
+// An empty template uses the instance connection name.
+func UnixSocketName(tmpl, inst string) (string, error) {
+	if tmpl == "" {
+		return inst, nil
+	}
+	project, region, name := splitInstanceName(inst)
+	var err error
+	n := unixSocketNamePlaceholder.ReplaceAllStringFunc(tmpl, func(p string) string {
+		switch p {
+		case "{project}":
+			return project
+		case "{region}":
+			return region
+		case "{instance}":
+			return name
+		case "{name}":
+			return inst
+		}
+		err = fmt.Errorf("unknown placeholder %v in Unix socket name template %q", p, tmpl)
+		return p
+	})
+	if err != nil {
+		return "", err
+	}
+	if n == "" || n == "." || n == ".." || strings.ContainsAny(n, `/\`) {
+		return "", fmt.Errorf(
+			"Unix socket name template %q is not a valid file name for %v: %q",
+			tmpl, inst, n,
+		)
+	}
+	return n, nil
+}
+
+// splitInstanceName splits an instance connection name into its project,
+// region, and instance. Domain-scoped projects keep their domain, e.g.,
+// "example.com:my-project". A name that is not in the
+// project:region:instance form is returned as the instance.
+func splitInstanceName(inst string) (project, region, name string) {
+	parts := strings.Split(inst, ":")
+	if len(parts) < 3 {
+		return "", "", inst
+	}
+	n := len(parts)
+	return strings.Join(parts[:n-2], ":"), parts[n-2], parts[n-1]
+}